@@ -0,0 +1,108 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// daemonStatusMsg and daemonClosedMsg carry the subscription they came
+// from, so messages from a connection we already detached are ignored.
+type daemonStatusMsg struct {
+	updates <-chan TimerStatus
+	status  TimerStatus
+}
+
+type daemonClosedMsg struct {
+	updates <-chan TimerStatus
+}
+
+func waitForDaemon(updates <-chan TimerStatus) tea.Cmd {
+	return func() tea.Msg {
+		status, ok := <-updates
+		if !ok {
+			return daemonClosedMsg{updates: updates}
+		}
+		return daemonStatusMsg{updates: updates, status: status}
+	}
+}
+
+// attachDaemon shows the daemon's timer. The timer keeps running in the
+// daemon when we detach or quit.
+func (m *App) attachDaemon() (tea.Model, tea.Cmd) {
+	client, err := dialDaemon(socketPath())
+	if err != nil {
+		m.notice = "No background timer is running. Start one with `romodoro daemon`."
+		return m, nil
+	}
+	status, updates, err := client.Subscribe()
+	if err != nil {
+		client.Close()
+		m.notice = "Could not attach: " + err.Error()
+		return m, nil
+	}
+
+	m.daemon = client
+	m.daemonUpdates = updates
+	m.attached = status
+	m.notice = ""
+	m.state = StateAttached
+	return m, waitForDaemon(updates)
+}
+
+func (m *App) detachDaemon() {
+	if m.daemon != nil {
+		m.daemon.Close()
+	}
+	m.daemon = nil
+	m.daemonUpdates = nil
+	m.state = StateMainMenu
+}
+
+func (m *App) updateDaemonStatus(msg daemonStatusMsg) (tea.Model, tea.Cmd) {
+	if msg.updates != m.daemonUpdates {
+		return m, nil
+	}
+	m.attached = msg.status
+	return m, waitForDaemon(msg.updates)
+}
+
+func (m *App) updateDaemonClosed(msg daemonClosedMsg) (tea.Model, tea.Cmd) {
+	if msg.updates != m.daemonUpdates {
+		return m, nil
+	}
+	m.detachDaemon()
+	m.notice = "The background timer stopped."
+	return m, nil
+}
+
+func (m *App) updateAttached(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var method string
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.Pause.has(key):
+		method = "pause"
+	case keys.Resume.has(key):
+		method = "resume"
+	case keys.Skip.has(key):
+		method = "skip"
+	case keys.Stop.has(key):
+		method = "stop"
+	case keys.Detach.has(key), keys.Menu.has(key):
+		m.detachDaemon()
+		return m, nil
+	default:
+		return m, nil
+	}
+
+	status, ok, err := callDaemon(method, nil)
+	if !ok {
+		m.notice = "The background timer is not reachable."
+		return m, nil
+	}
+	if err != nil {
+		m.notice = err.Error()
+		return m, nil
+	}
+	m.notice = ""
+	m.attached = status
+	return m, nil
+}
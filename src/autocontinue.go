@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const defaultAutoContinueGrace = 10
+
+// nextAutoContinue steps a toggle through off → immediate → countdown → off.
+func nextAutoContinue(enabled bool, graceSeconds int) (bool, int) {
+	switch {
+	case !enabled:
+		return true, 0
+	case graceSeconds == 0:
+		return true, defaultAutoContinueGrace
+	default:
+		return false, 0
+	}
+}
+
+// autoContinue reports whether the next split should start on its own once
+// the current one (or its whole cycle) finishes. The session setting wins
+// over the preset the split was started from.
+func (m *App) autoContinue() (enabled bool, graceSeconds int) {
+	if m.session != nil && m.session.AutoContinue {
+		return true, m.session.AutoContinueGraceSeconds
+	}
+	if m.activePreset != nil && m.activePreset.AutoContinue {
+		return true, m.activePreset.AutoContinueGraceSeconds
+	}
+	return false, 0
+}
+
+func (m *App) toggleSessionAutoContinue() {
+	if m.session == nil {
+		return
+	}
+	enabled, grace := nextAutoContinue(m.session.AutoContinue, m.session.AutoContinueGraceSeconds)
+	if err := m.store.SetSessionAutoContinue(m.session.ID, enabled, grace); err != nil {
+		return
+	}
+	m.session.AutoContinue = enabled
+	m.session.AutoContinueGraceSeconds = grace
+}
+
+func (m *App) togglePresetAutoContinue() {
+	if m.selectedPreset >= len(m.presets) {
+		return
+	}
+	preset := &m.presets[m.selectedPreset]
+	enabled, grace := nextAutoContinue(preset.AutoContinue, preset.AutoContinueGraceSeconds)
+	if err := m.store.SetPresetAutoContinue(preset.ID, enabled, grace); err != nil {
+		return
+	}
+	preset.AutoContinue = enabled
+	preset.AutoContinueGraceSeconds = grace
+}
+
+// beginAutoContinue restarts the same plan, either right away or after a
+// countdown the user can cancel.
+func (m *App) beginAutoContinue(graceSeconds int) (tea.Model, tea.Cmd) {
+	if graceSeconds <= 0 {
+		return m.startSplit(1)
+	}
+	m.autoContinueAt = time.Now().Add(time.Duration(graceSeconds) * time.Second)
+	m.state = StateAutoContinue
+	return m, m.tickCmd()
+}
+
+func (m *App) autoContinueRemaining() int {
+	return int(time.Until(m.autoContinueAt).Round(time.Second).Seconds())
+}
+
+func (m *App) updateAutoContinueTick() (tea.Model, tea.Cmd) {
+	if m.autoContinueRemaining() <= 0 {
+		return m.startSplit(1)
+	}
+	return m, m.tickCmd()
+}
+
+func (m *App) updateAutoContinue(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.StartNext.has(key):
+		return m.startSplit(1)
+	case keys.CancelNext.has(key):
+		m.state = StateTimerSetup
+		m.resetTimerSetup()
+		return m, textinput.Blink
+	case keys.Menu.has(key):
+		m.state = StateMainMenu
+		return m, nil
+	}
+	return m, nil
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"romodoro/timer"
+)
+
+// Exit codes are part of the command line interface; scripts rely on them,
+// so never renumber them.
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitUsage      = 2
+	ExitNotRunning = 3
+	ExitNotFound   = 4
+	ExitRunning    = 5
+	ExitNoDaemon   = 6
+	ExitBadConfig  = 7
+)
+
+const usage = `Usage:
+  romodoro [--db PATH] [command]
+
+Commands:
+  romodoro                           start the interactive timer
+  romodoro start [flags]             start a split in the background
+  romodoro status [--format FMT]     show the running split
+  romodoro stats [--days N]          totals for recent sessions
+  romodoro stop                      stop the running split
+  romodoro pause | resume | skip     control the daemon's split
+  romodoro sessions list             list all sessions
+  romodoro sessions show ID          show a session and its splits
+  romodoro sessions delete ID        delete a session and its splits
+  romodoro export [flags]            export sessions or splits as CSV or JSON
+  romodoro daemon [--socket PATH]    keep timers running in the background
+  romodoro serve [--addr ADDR]       serve the HTTP API on localhost
+  romodoro config validate [PATH]    check the config file
+  romodoro config path | default     show the config location or defaults
+
+The database is --db, else $ROMODORO_DB, else $XDG_DATA_HOME/romodoro/sessions.db.
+status, stats and sessions list/show accept --json or --output json|ndjson|table.
+
+Exit codes: 0 ok, 1 error, 2 usage, 3 no split running,
+4 session not found, 5 a split is already running, 6 no daemon running,
+7 invalid config file
+`
+
+// runCommand runs a non-interactive subcommand and returns its exit code.
+func runCommand(store Store, args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "start":
+		return cmdStart(store, args[1:], stdout, stderr)
+	case "status":
+		return cmdStatus(store, args[1:], stdout, stderr)
+	case "stop":
+		return cmdStop(store, args[1:], stdout, stderr)
+	case "pause", "resume", "skip":
+		return cmdControl(args[0], args[1:], stdout, stderr)
+	case "stats":
+		return cmdStats(store, args[1:], stdout, stderr)
+	case "sessions":
+		return cmdSessions(store, args[1:], stdout, stderr)
+	case "daemon":
+		return cmdDaemon(store, args[1:], stdout, stderr)
+	case "serve":
+		return cmdServe(store, args[1:], stdout, stderr)
+	case "export":
+		return cmdExport(store, args[1:], stdout, stderr)
+	case "config":
+		return cmdConfig(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	}
+	fmt.Fprintf(stderr, "romodoro: unknown command %q\n\n%s", args[0], usage)
+	return ExitUsage
+}
+
+// parseFlags parses a subcommand's flags, reporting the exit code to use if
+// parsing stopped the command.
+func parseFlags(flags *flag.FlagSet, args []string, stderr io.Writer) (int, bool) {
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK, false
+		}
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
+
+// exitCode maps an error from the daemon to an exit code.
+func exitCode(err error) int {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) && rpcErr.Code > 0 {
+		return rpcErr.Code
+	}
+	return ExitError
+}
+
+// runningSplit returns the split that is still being timed, with its
+// timer rebuilt as of now. The TUI and the command line share this state
+// through the in-progress split rows. Orphans, splits that splitLive says
+// nothing is timing, are skipped and left for the recovery path; the store
+// is never changed.
+func runningSplit(store Store, now time.Time) (*PomodoroSplit, *timer.Timer, error) {
+	splits, err := store.GetInProgressSplits()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range splits {
+		split := &splits[i]
+		t, err := splitTimer(store, split, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if splitLive(store, split, t) {
+			return split, t, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func cmdStart(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("start", flag.ContinueOnError)
+	defaults := currentConfig().Timer
+	focus := flags.Int("focus", defaults.FocusMinutes, "focus `minutes`")
+	rest := flags.Int("rest", defaults.RestMinutes, "rest `minutes`")
+	sessionName := flags.String("session", "", "session `name`; reuses the newest session with that name")
+	flowtime := flags.Bool("flowtime", false, "count focus up instead of down")
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+	if flags.NArg() > 0 || *focus <= 0 || *rest < 0 {
+		fmt.Fprintln(stderr, "romodoro start: focus must be positive and rest must not be negative")
+		return ExitUsage
+	}
+
+	// Only a daemon times the split to its end, running hooks and
+	// notifications on the way, so one is started if none is running
+	params := startParams{Focus: *focus, Rest: *rest, Session: *sessionName, Flowtime: *flowtime}
+	status, ok, err := callDaemon("start", params)
+	if !ok {
+		if err := settleOrphans(store, time.Now()); err != nil {
+			fmt.Fprintln(stderr, "romodoro start:", err)
+			return ExitError
+		}
+		if err := spawnDaemon(); err != nil {
+			fmt.Fprintln(stderr, "romodoro start: could not start the daemon:", err)
+			return ExitNoDaemon
+		}
+		status, _, err = callDaemon("start", params)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro start:", err)
+		return exitCode(err)
+	}
+	fmt.Fprintf(stdout, "Started split %d in session %q\n", status.SplitID, status.SessionName)
+	return ExitOK
+}
+
+func findOrCreateSession(store Store, name string, now time.Time) (*Session, error) {
+	if name == "" {
+		name = defaultSessionName(now)
+	} else {
+		sessions, err := store.GetAllSessions()
+		if err != nil {
+			return nil, err
+		}
+		// Sessions come newest first
+		for i := range sessions {
+			if sessions[i].Name == name {
+				return &sessions[i], nil
+			}
+		}
+	}
+
+	session, err := store.CreateSession(name)
+	if err == nil {
+		emitEvent(store, HookSessionCreated, session, nil)
+	}
+	return session, err
+}
+
+func cmdStatus(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	format := flags.String("format", "", "Go `template`, or one of tmux, waybar and short")
+	output := addOutputFlags(flags)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+	outputFormat, err := output.format()
+	if err == nil && *format != "" && outputFormat != OutputTable {
+		err = errors.New("--format cannot be combined with JSON output")
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro status:", err)
+		return ExitUsage
+	}
+
+	status, err := currentStatus(store)
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro status:", err)
+		return ExitError
+	}
+
+	if outputFormat != OutputTable {
+		if err := writeOne(stdout, outputFormat, "status", status); err != nil {
+			fmt.Fprintln(stderr, "romodoro status:", err)
+			return ExitError
+		}
+		if status.State == "idle" {
+			return ExitNotRunning
+		}
+		return ExitOK
+	}
+
+	if *format != "" {
+		tmpl, err := parseStatusFormat(*format)
+		if err != nil {
+			fmt.Fprintln(stderr, "romodoro status:", err)
+			return ExitUsage
+		}
+		if err := renderStatus(stdout, tmpl, status); err != nil {
+			// Almost always a field the template got wrong
+			fmt.Fprintln(stderr, "romodoro status:", err)
+			return ExitUsage
+		}
+		if status.State == "idle" {
+			return ExitNotRunning
+		}
+		return ExitOK
+	}
+
+	if status.State == "idle" {
+		fmt.Fprintln(stdout, "idle")
+		return ExitNotRunning
+	}
+
+	clock := fmt.Sprintf("%s remaining", formatClock(status.RemainingSeconds))
+	if status.RemainingSeconds < 0 {
+		clock = fmt.Sprintf("%s overtime", formatClock(-status.RemainingSeconds))
+	}
+	if status.CountingUp {
+		clock = fmt.Sprintf("%s elapsed", formatClock(status.ElapsedSeconds))
+	}
+	if status.State == "paused" {
+		clock += ", paused"
+	}
+	fmt.Fprintf(stdout, "%s %s (session %q)\n", status.Phase, clock, status.SessionName)
+	return ExitOK
+}
+
+// currentStatus asks the daemon for its status, falling back to the
+// in-progress split in the database when no daemon is running or it is
+// idle while the TUI times a split.
+func currentStatus(store Store) (TimerStatus, error) {
+	status, _, err := timerStatus(store)
+	return status, err
+}
+
+// timerStatus is currentStatus, also reporting whether a daemon answered.
+func timerStatus(store Store) (status TimerStatus, daemonUp bool, err error) {
+	status, daemonUp, err = callDaemon("status", nil)
+	if err != nil || (daemonUp && status.State != "idle") {
+		return status, daemonUp, err
+	}
+	status, err = storedStatus(store)
+	return status, daemonUp, err
+}
+
+// storedStatus rebuilds the status of the split that runningSplit finds.
+func storedStatus(store Store) (TimerStatus, error) {
+	split, t, err := runningSplit(store, time.Now())
+	if err != nil || split == nil {
+		return TimerStatus{State: "idle"}, err
+	}
+	session, err := store.GetSession(split.SessionID)
+	if err != nil {
+		return TimerStatus{}, err
+	}
+
+	status := TimerStatus{
+		State:            t.State().String(),
+		Phase:            t.Phase().String(),
+		RemainingSeconds: int(t.Remaining().Round(time.Second).Seconds()),
+		ElapsedSeconds:   int(t.Elapsed().Seconds()),
+		CountingUp:       t.CountingUp(),
+		SessionID:        session.ID,
+		SessionName:      session.Name,
+		SplitID:          split.ID,
+		SplitNumber:      splitNumber(store, split),
+	}
+	// The process timing it knows more, such as restarts; it is still running
+	if t.State() == timer.StateFinished {
+		status.State = "running"
+	}
+	if status.CountingUp {
+		status.RemainingSeconds = 0
+	}
+	return status, nil
+}
+
+// splitNumber is the split's 1-based position within its session.
+func splitNumber(store Store, split *PomodoroSplit) int {
+	splits, err := store.GetSessionSplits(split.SessionID)
+	if err != nil {
+		return 0
+	}
+	for i := range splits {
+		if splits[i].ID == split.ID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func cmdStop(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stop", flag.ContinueOnError)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	if _, ok, err := callDaemon("stop", nil); ok {
+		if err != nil {
+			fmt.Fprintln(stderr, "romodoro stop:", err)
+			return exitCode(err)
+		}
+		fmt.Fprintln(stdout, "Stopped the daemon's split")
+		return ExitOK
+	}
+
+	now := time.Now()
+	split, _, err := runningSplit(store, now)
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro stop:", err)
+		return ExitError
+	}
+	if split == nil {
+		fmt.Fprintln(stderr, "romodoro stop: no split is running")
+		return ExitNotRunning
+	}
+	if ownedElsewhere(store, split.ID) {
+		fmt.Fprintf(stderr, "romodoro stop: split %d is being timed by another romodoro process; stop it there\n", split.ID)
+		return ExitRunning
+	}
+
+	if err := settleSplit(store, split, "cancelled", now); err != nil {
+		fmt.Fprintln(stderr, "romodoro stop:", err)
+		return ExitError
+	}
+	if session, err := store.GetSession(split.SessionID); err == nil {
+		emitEvent(store, HookSplitCancelled, session, split)
+	}
+	fmt.Fprintf(stdout, "Stopped split %d after %s focus, %s rest\n", split.ID,
+		formatClock(split.ActualFocusSeconds), formatClock(split.ActualRestSeconds))
+	return ExitOK
+}
+
+// cmdControl pauses, resumes or skips; only a daemon can hold a timer
+// still long enough for these.
+func cmdControl(method string, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(method, flag.ContinueOnError)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	status, ok, err := callDaemon(method, nil)
+	if !ok {
+		fmt.Fprintf(stderr, "romodoro %s: no daemon is running; start one with `romodoro daemon`\n", method)
+		return ExitNoDaemon
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "romodoro %s: %v\n", method, err)
+		return exitCode(err)
+	}
+	fmt.Fprintf(stdout, "%s %s\n", status.State, status.Phase)
+	return ExitOK
+}
+
+func cmdSessions(store Store, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "list":
+		return cmdSessionsList(store, args[1:], stdout, stderr)
+	case "show":
+		return cmdSessionsShow(store, args[1:], stdout, stderr)
+	case "delete":
+		return cmdSessionsDelete(store, args[1:], stdout, stderr)
+	}
+
+	fmt.Fprintf(stderr, "romodoro sessions: unknown command %q\n\n%s", args[0], usage)
+	return ExitUsage
+}
+
+func cmdSessionsList(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sessions list", flag.ContinueOnError)
+	output := addOutputFlags(flags)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+	format, err := output.format()
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions list:", err)
+		return ExitUsage
+	}
+
+	sessions, err := store.GetAllSessions()
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions list:", err)
+		return ExitError
+	}
+
+	if format != OutputTable {
+		if err := writeList(stdout, format, "sessions", "session", sessions); err != nil {
+			fmt.Fprintln(stderr, "romodoro sessions list:", err)
+			return ExitError
+		}
+		return ExitOK
+	}
+
+	fmt.Fprintf(stdout, "%-6s %-16s %-10s %-10s %-5s %s\n", "ID", "Started", "Focus", "Rest", "Int.", "Name")
+	for _, session := range sessions {
+		fmt.Fprintf(stdout, "%-6d %-16s %-10s %-10s %-5d %s\n",
+			session.ID,
+			session.StartTime.Local().Format("2006-01-02 15:04"),
+			formatClock(session.TotalFocusSeconds),
+			formatClock(session.TotalRestSeconds),
+			session.InterruptionCount,
+			session.Name,
+		)
+	}
+	return ExitOK
+}
+
+// sessionDetail is a session together with its splits.
+type sessionDetail struct {
+	Session
+	Splits []PomodoroSplit `json:"splits"`
+}
+
+func cmdSessionsShow(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sessions show", flag.ContinueOnError)
+	output := addOutputFlags(flags)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+	format, err := output.format()
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions show:", err)
+		return ExitUsage
+	}
+	id, code := sessionIDArg("sessions show", flags.Args(), stderr)
+	if code != ExitOK {
+		return code
+	}
+
+	session, err := store.GetSession(id)
+	if errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "romodoro sessions show: session %d not found\n", id)
+		return ExitNotFound
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions show:", err)
+		return ExitError
+	}
+	splits, err := store.GetSessionSplits(id)
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions show:", err)
+		return ExitError
+	}
+
+	switch format {
+	case OutputJSON:
+		if splits == nil {
+			splits = []PomodoroSplit{}
+		}
+		err = writeOne(stdout, format, "session_detail", sessionDetail{Session: *session, Splits: splits})
+	case OutputNDJSON:
+		// The session first, then one line per split
+		if err = writeOne(stdout, format, "session", session); err == nil {
+			err = writeList(stdout, format, "splits", "split", splits)
+		}
+	default:
+		fmt.Fprintf(stdout, "Session %d: %s\n", session.ID, session.Name)
+		fmt.Fprintf(stdout, "Focus %s, rest %s, %d interruptions\n\n",
+			formatClock(session.TotalFocusSeconds), formatClock(session.TotalRestSeconds), session.InterruptionCount)
+		fmt.Fprintf(stdout, "%-6s %-16s %-10s %-12s %-10s %s\n", "ID", "Started", "Plan", "Status", "Focus", "Rest")
+		for _, split := range splits {
+			plan := formatPlan(split.FocusMinutes, split.RestMinutes, 1, 0)
+			if split.Mode == SplitModeFlowtime {
+				plan = "flowtime"
+			}
+			fmt.Fprintf(stdout, "%-6d %-16s %-10s %-12s %-10s %s\n",
+				split.ID,
+				split.StartTime.Local().Format("2006-01-02 15:04"),
+				plan,
+				split.Status,
+				formatClock(split.ActualFocusSeconds),
+				formatClock(split.ActualRestSeconds),
+			)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions show:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+func cmdSessionsDelete(store Store, args []string, stdout, stderr io.Writer) int {
+	id, code := sessionIDArg("sessions delete", args, stderr)
+	if code != ExitOK {
+		return code
+	}
+	if _, err := store.GetSession(id); errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "romodoro sessions delete: session %d not found\n", id)
+		return ExitNotFound
+	}
+	if err := store.DeleteSession(id); err != nil {
+		fmt.Fprintln(stderr, "romodoro sessions delete:", err)
+		return ExitError
+	}
+	fmt.Fprintf(stdout, "Deleted session %d\n", id)
+	return ExitOK
+}
+
+func sessionIDArg(command string, args []string, stderr io.Writer) (int, int) {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "romodoro %s: expected exactly one session ID\n", command)
+		return 0, ExitUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "romodoro %s: invalid session ID %q\n", command, args[0])
+		return 0, ExitUsage
+	}
+	return id, ExitOK
+}
+
+// formatClock renders seconds as mm:ss, or h:mm:ss from an hour up.
+func formatClock(seconds int) string {
+	if seconds < 0 {
+		return "-" + formatClock(-seconds)
+	}
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// isolateDaemon gives the test a socket and config of its own, so
+// callDaemon only finds a daemon the test started.
+func isolateDaemon(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	previous := socketStore
+	setSocketStore(BackendMemory, t.Name())
+	t.Cleanup(func() { socketStore = previous })
+}
+
+// startTestDaemon runs a daemon on store for the rest of the test, on a
+// socket of its own that callDaemon finds.
+func startTestDaemon(t *testing.T, store Store) {
+	t.Helper()
+	isolateDaemon(t)
+	listener, err := listenSocket(socketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runDaemon(ctx, store, listener, io.Discard) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestCurrentStatusWithIdleDaemon(t *testing.T) {
+	tests := []struct {
+		name    string
+		split   bool
+		state   string
+		phase   string
+		splitID bool
+	}{
+		{name: "nothing running", state: "idle"},
+		{name: "split timed by the TUI", split: true, state: "running", phase: "focus", splitID: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store Store) {
+				// Soft end keeps the split from running out by itself, so
+				// only its owner makes it count as running. The parent
+				// process stands in for the TUI, as the daemon in this
+				// process would adopt a split this process owns.
+				var split *PomodoroSplit
+				if test.split {
+					split = startTestSplit(t, store, PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}, true)
+					if _, err := store.SetSplitOwner(split.ID, 0, os.Getppid()); err != nil {
+						t.Fatal(err)
+					}
+				}
+				startTestDaemon(t, store)
+
+				status, err := currentStatus(store)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status.State != test.state || status.Phase != test.phase {
+					t.Errorf("got %q %q, want %q %q", status.State, status.Phase, test.state, test.phase)
+				}
+				if test.splitID && status.SplitID != split.ID {
+					t.Errorf("split %d, want %d", status.SplitID, split.ID)
+				}
+			})
+		})
+	}
+}
+
+func TestRunCommandExitCodes(t *testing.T) {
+	// seeds put the store, and the daemon if there is one, in a known state
+	session := func(t *testing.T, store Store) {
+		if _, err := store.CreateSession("Seeded"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started := func(t *testing.T, store Store) {
+		if _, _, err := callDaemon("start", startParams{Focus: 25, Rest: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ownSplit := func(t *testing.T, store Store) {
+		split := startTestSplit(t, store, PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}, true)
+		if _, err := claimSplit(store, split.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		daemon bool
+		seed   func(t *testing.T, store Store)
+		args   []string
+		code   int
+		// envelopes lists the type of each JSON document written, if any
+		envelopes []string
+	}{
+		{name: "unknown command", args: []string{"bogus"}, code: ExitUsage},
+		{name: "help", args: []string{"--help"}, code: ExitOK},
+		{name: "status idle", args: []string{"status"}, code: ExitNotRunning},
+		{name: "status idle as JSON", args: []string{"status", "--json"}, code: ExitNotRunning, envelopes: []string{"status"}},
+		{name: "status unknown output", args: []string{"status", "--output", "yaml"}, code: ExitUsage},
+		{name: "status format with JSON", args: []string{"status", "--json", "--format", "short"}, code: ExitUsage},
+		{name: "status of an owned split", seed: ownSplit, args: []string{"status", "--output", "ndjson"}, code: ExitOK, envelopes: []string{"status"}},
+		{name: "start with bad minutes", args: []string{"start", "--focus", "0"}, code: ExitUsage},
+		{name: "stop with nothing running", args: []string{"stop"}, code: ExitNotRunning},
+		{name: "stop an owned split", seed: ownSplit, args: []string{"stop"}, code: ExitOK},
+		{name: "pause without a daemon", args: []string{"pause"}, code: ExitNoDaemon},
+		{name: "sessions without a command", args: []string{"sessions"}, code: ExitUsage},
+		{name: "sessions list as JSON", seed: session, args: []string{"sessions", "list", "--json"}, code: ExitOK, envelopes: []string{"sessions"}},
+		{name: "empty sessions list as JSON", args: []string{"sessions", "list", "--json"}, code: ExitOK, envelopes: []string{"sessions"}},
+		{name: "sessions list as NDJSON", seed: session, args: []string{"sessions", "list", "--output", "ndjson"}, code: ExitOK, envelopes: []string{"session"}},
+		{name: "sessions show unknown", args: []string{"sessions", "show", "99"}, code: ExitNotFound},
+		{name: "sessions show bad ID", args: []string{"sessions", "show", "first"}, code: ExitUsage},
+		{name: "sessions show as NDJSON", seed: ownSplit, args: []string{"sessions", "show", "--output", "ndjson", "1"}, code: ExitOK, envelopes: []string{"session", "split"}},
+		{name: "sessions delete unknown", args: []string{"sessions", "delete", "99"}, code: ExitNotFound},
+		{name: "stats negative days", args: []string{"stats", "--days", "-1"}, code: ExitUsage},
+		{name: "stats extra argument", args: []string{"stats", "week"}, code: ExitUsage},
+		{name: "stats as JSON", args: []string{"stats", "--json"}, code: ExitOK, envelopes: []string{"stats"}},
+		{name: "start through the daemon", daemon: true, args: []string{"start"}, code: ExitOK},
+		{name: "start twice", daemon: true, seed: started, args: []string{"start"}, code: ExitRunning},
+		{name: "status from the daemon", daemon: true, seed: started, args: []string{"status", "--json"}, code: ExitOK, envelopes: []string{"status"}},
+		{name: "pause through the daemon", daemon: true, seed: started, args: []string{"pause"}, code: ExitOK},
+		{name: "resume with nothing paused", daemon: true, seed: started, args: []string{"resume"}, code: ExitNotRunning},
+		{name: "stop through the daemon", daemon: true, seed: started, args: []string{"stop"}, code: ExitOK},
+		{name: "stop an idle daemon", daemon: true, args: []string{"stop"}, code: ExitNotRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			if test.daemon {
+				startTestDaemon(t, store)
+			} else {
+				isolateDaemon(t)
+			}
+			if test.seed != nil {
+				test.seed(t, store)
+			}
+
+			var stdout, stderr bytes.Buffer
+			if code := runCommand(store, test.args, &stdout, &stderr); code != test.code {
+				t.Fatalf("exit %d, want %d; stderr: %s", code, test.code, stderr.String())
+			}
+			if test.envelopes == nil {
+				return
+			}
+
+			output := stdout.String()
+			var types []string
+			decoder := json.NewDecoder(strings.NewReader(output))
+			for decoder.More() {
+				var document envelope
+				if err := decoder.Decode(&document); err != nil {
+					t.Fatal(err)
+				}
+				if document.SchemaVersion != outputSchemaVersion {
+					t.Errorf("schema version %d, want %d", document.SchemaVersion, outputSchemaVersion)
+				}
+				if document.Data == nil {
+					t.Errorf("%s envelope without data", document.Type)
+				}
+				types = append(types, document.Type)
+			}
+			if strings.Join(types, " ") != strings.Join(test.envelopes, " ") {
+				t.Errorf("envelopes %v, want %v", types, test.envelopes)
+			}
+			if slices.Contains(test.args, OutputNDJSON) && strings.Count(output, "\n") != len(types) {
+				t.Errorf("NDJSON is not one document per line:\n%s", output)
+			}
+		})
+	}
+}
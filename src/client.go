@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// The daemon speaks JSON-RPC 2.0 over a Unix socket, one message per line.
+// Every method returns the TimerStatus after it ran; subscribe additionally
+// streams "status" notifications until the connection is closed.
+
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError codes below zero are protocol errors; positive codes match the
+// command line exit codes.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// TimerStatus is a snapshot of the daemon's timer.
+type TimerStatus struct {
+	State            string `json:"state"` // idle, running or paused
+	Phase            string `json:"phase,omitempty"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+	ElapsedSeconds   int    `json:"elapsed_seconds"`
+	CountingUp       bool   `json:"counting_up"`
+	SessionID        int    `json:"session_id,omitempty"`
+	SessionName      string `json:"session_name,omitempty"`
+	SplitID          int    `json:"split_id,omitempty"`
+	SplitNumber      int    `json:"split_number,omitempty"` // 1-based position within the session
+}
+
+type startParams struct {
+	Focus    int    `json:"focus"`
+	Rest     int    `json:"rest"`
+	Session  string `json:"session,omitempty"`
+	Flowtime bool   `json:"flowtime,omitempty"`
+}
+
+// socketStore names the database this process uses, so that a daemon
+// only ever serves clients of its own database. storePath is the same
+// database, for the daemons spawnDaemon starts and for exportDir.
+var (
+	socketStore string
+	storePath   string
+)
+
+// setSocketStore records the backend and database path for socketPath.
+func setSocketStore(backend, path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	storePath = filepath.Clean(path)
+	if backend == "" {
+		backend = BackendSQLite
+	}
+	socketStore = backend + ":" + storePath
+}
+
+// socketPath is where the daemon for this database listens:
+// $XDG_RUNTIME_DIR/romodoro-<hash>.sock, or a per-user socket in the temp
+// directory, where <hash> is derived from the database path.
+func socketPath() string {
+	name := "romodoro"
+	if socketStore != "" {
+		sum := sha256.Sum256([]byte(socketStore))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, name+".sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d.sock", name, os.Getuid()))
+}
+
+type daemonClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+func dialDaemon(path string) (*daemonClient, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &daemonClient{conn: conn, scanner: bufio.NewScanner(conn)}, nil
+}
+
+func (c *daemonClient) Close() error {
+	return c.conn.Close()
+}
+
+// Call runs a method and waits for its response, skipping any
+// notifications that arrive in between.
+func (c *daemonClient) Call(method string, params any) (TimerStatus, error) {
+	var status TimerStatus
+
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	request := rpcMessage{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return status, err
+		}
+		request.Params = encoded
+	}
+	if err := json.NewEncoder(c.conn).Encode(request); err != nil {
+		return status, err
+	}
+
+	for c.scanner.Scan() {
+		var response rpcMessage
+		if err := json.Unmarshal(c.scanner.Bytes(), &response); err != nil {
+			return status, err
+		}
+		if string(response.ID) != string(id) {
+			continue
+		}
+		if response.Error != nil {
+			return status, response.Error
+		}
+		return status, json.Unmarshal(response.Result, &status)
+	}
+	if err := c.scanner.Err(); err != nil {
+		return status, err
+	}
+	return status, errors.New("daemon closed the connection")
+}
+
+// Subscribe asks for status updates. The client must not be used for
+// anything else afterwards; the channel is closed when the connection is.
+func (c *daemonClient) Subscribe() (TimerStatus, <-chan TimerStatus, error) {
+	status, err := c.Call("subscribe", nil)
+	if err != nil {
+		return status, nil, err
+	}
+
+	updates := make(chan TimerStatus, 8)
+	go func() {
+		defer close(updates)
+		for c.scanner.Scan() {
+			var message rpcMessage
+			if json.Unmarshal(c.scanner.Bytes(), &message) != nil || message.Method != "status" {
+				continue
+			}
+			var update TimerStatus
+			if json.Unmarshal(message.Params, &update) != nil {
+				continue
+			}
+			// Another update follows within a second, so a slow reader
+			// only misses stale ones
+			select {
+			case updates <- update:
+			default:
+			}
+		}
+	}()
+	return status, updates, nil
+}
+
+// callDaemon runs a single method on the daemon. ok is false when no
+// daemon is listening.
+func callDaemon(method string, params any) (status TimerStatus, ok bool, err error) {
+	client, err := dialDaemon(socketPath())
+	if err != nil {
+		return status, false, nil
+	}
+	defer client.Close()
+
+	status, err = client.Call(method, params)
+	return status, true, err
+}
+
+// spawnDaemon starts `romodoro daemon` for this database in the background,
+// detached from the terminal, and waits until it answers.
+func spawnDaemon() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "--db", storePath, "daemon")
+	cmd.SysProcAttr = detachedProcess()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		if client, err := dialDaemon(socketPath()); err == nil {
+			client.Close()
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("the daemon exited: %v", err)
+		case <-deadline:
+			return errors.New("the daemon did not start listening")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
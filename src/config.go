@@ -0,0 +1,574 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Config is read from config.toml. Every field has a default, so the file
+// only needs the settings that differ.
+type Config struct {
+	Timer         TimerConfig         `toml:"timer"`
+	Notifications NotificationsConfig `toml:"notifications"`
+	Hooks         HooksConfig         `toml:"hooks"`
+	Webhooks      []WebhookConfig     `toml:"webhooks"`
+	Theme         ThemeConfig         `toml:"theme"`
+	Keymap        KeymapConfig        `toml:"keymap"`
+}
+
+type TimerConfig struct {
+	FocusMinutes     int `toml:"focus_minutes"`
+	RestMinutes      int `toml:"rest_minutes"`
+	LongBreakMinutes int `toml:"long_break_minutes"`
+	ExtendMinutes    int `toml:"extend_minutes"`
+}
+
+// NotificationsConfig picks the notifiers. An empty player or sound lets
+// the sound notifier find one for the platform.
+type NotificationsConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Backends []string `toml:"backends"`
+	Player   string   `toml:"player"`
+	Sound    string   `toml:"sound"`
+	Command  string   `toml:"command"`
+}
+
+// HooksConfig lists the shell commands to run on each event.
+type HooksConfig struct {
+	TimeoutSeconds int      `toml:"timeout_seconds"`
+	SessionCreated []string `toml:"session_created"`
+	FocusStart     []string `toml:"focus_start"`
+	FocusEnd       []string `toml:"focus_end"`
+	RestStart      []string `toml:"rest_start"`
+	RestEnd        []string `toml:"rest_end"`
+	Paused         []string `toml:"paused"`
+	Resumed        []string `toml:"resumed"`
+	SplitCancelled []string `toml:"split_cancelled"`
+}
+
+func (h HooksConfig) commands(event string) []string {
+	switch event {
+	case HookSessionCreated:
+		return h.SessionCreated
+	case HookFocusStart:
+		return h.FocusStart
+	case HookFocusEnd:
+		return h.FocusEnd
+	case HookRestStart:
+		return h.RestStart
+	case HookRestEnd:
+		return h.RestEnd
+	case HookPaused:
+		return h.Paused
+	case HookResumed:
+		return h.Resumed
+	case HookSplitCancelled:
+		return h.SplitCancelled
+	}
+	return nil
+}
+
+// WebhookConfig is one [[webhooks]] target. An empty Events list sends
+// every event.
+type WebhookConfig struct {
+	URL    string   `toml:"url"`
+	Secret string   `toml:"secret"`
+	Events []string `toml:"events"`
+}
+
+type ThemeConfig struct {
+	Accent        string `toml:"accent"`
+	Text          string `toml:"text"`
+	Muted         string `toml:"muted"`
+	Header        string `toml:"header"`
+	Focus         string `toml:"focus"`
+	Rest          string `toml:"rest"`
+	Paused        string `toml:"paused"`
+	ProgressStart string `toml:"progress_start"`
+	ProgressEnd   string `toml:"progress_end"`
+	ProgressWidth int    `toml:"progress_width"`
+}
+
+// KeymapConfig lists the keys for each action, in Bubble Tea's key names
+// ("enter", "esc", "ctrl+x", "a"). Letters match either case.
+type KeymapConfig struct {
+	Quit         keyList `toml:"quit"`
+	Pause        keyList `toml:"pause"`
+	Resume       keyList `toml:"resume"`
+	Skip         keyList `toml:"skip"`
+	Extend       keyList `toml:"extend"`
+	Restart      keyList `toml:"restart"`
+	Finish       keyList `toml:"finish"`
+	Acknowledge  keyList `toml:"acknowledge"`
+	SoftEnd      keyList `toml:"soft_end"`
+	AutoContinue keyList `toml:"auto_continue"`
+	Back         keyList `toml:"back"`
+	Menu         keyList `toml:"menu"`
+
+	// Main menu
+	ContinueSession keyList `toml:"continue_session"`
+	BrowseSessions  keyList `toml:"browse_sessions"`
+	NewSession      keyList `toml:"new_session"`
+	Attach          keyList `toml:"attach"`
+
+	// Session browser and preset picker
+	Delete   keyList `toml:"delete"`
+	Export   keyList `toml:"export"`
+	Custom   keyList `toml:"custom"`
+	Flowtime keyList `toml:"flowtime"`
+
+	// Paused screen
+	Internal keyList `toml:"internal"`
+	External keyList `toml:"external"`
+	Reason   keyList `toml:"reason"`
+
+	// Attached to the daemon
+	Stop   keyList `toml:"stop"`
+	Detach keyList `toml:"detach"`
+
+	// Auto-continue countdown
+	StartNext  keyList `toml:"start_next"`
+	CancelNext keyList `toml:"cancel_next"`
+
+	// Unfinished split found on startup
+	ResumeSplit   keyList `toml:"resume_split"`
+	CompleteSplit keyList `toml:"complete_split"`
+	CancelSplit   keyList `toml:"cancel_split"`
+}
+
+type keyList []string
+
+func (k keyList) has(key string) bool {
+	for _, candidate := range k {
+		if candidate == key || (len(candidate) == 1 && strings.EqualFold(candidate, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Timer: TimerConfig{
+			FocusMinutes:     25,
+			RestMinutes:      5,
+			LongBreakMinutes: 15,
+			ExtendMinutes:    5,
+		},
+		Notifications: NotificationsConfig{
+			Enabled:  true,
+			Backends: []string{NotifierAuto},
+		},
+		Hooks: HooksConfig{
+			TimeoutSeconds: 10,
+		},
+		Theme: ThemeConfig{
+			Accent:        "#7D56F4",
+			Text:          "#FFFFFF",
+			Muted:         "#626262",
+			Header:        "#04B575",
+			Focus:         "#FF6B6B",
+			Rest:          "#4ECDC4",
+			Paused:        "#FFE66D",
+			ProgressStart: "#5A56E0",
+			ProgressEnd:   "#EE6FF8",
+			ProgressWidth: 60,
+		},
+		Keymap: KeymapConfig{
+			Quit:         keyList{"q", "ctrl+c"},
+			Pause:        keyList{"p"},
+			Resume:       keyList{"s", "c"},
+			Skip:         keyList{"n"},
+			Extend:       keyList{"+", "="},
+			Restart:      keyList{"r"},
+			Finish:       keyList{"f"},
+			Acknowledge:  keyList{"enter"},
+			SoftEnd:      keyList{"o"},
+			AutoContinue: keyList{"a"},
+			Back:         keyList{"b"},
+			Menu:         keyList{"m"},
+
+			ContinueSession: keyList{"1"},
+			BrowseSessions:  keyList{"2"},
+			NewSession:      keyList{"3"},
+			Attach:          keyList{"4"},
+
+			Delete:   keyList{"x"},
+			Export:   keyList{"e"},
+			Custom:   keyList{"c"},
+			Flowtime: keyList{"f"},
+
+			Internal: keyList{"i"},
+			External: keyList{"e"},
+			Reason:   keyList{"t"},
+
+			Stop:   keyList{"x"},
+			Detach: keyList{"d", "esc"},
+
+			StartNext:  keyList{"enter"},
+			CancelNext: keyList{"x", "esc"},
+
+			ResumeSplit:   keyList{"r"},
+			CompleteSplit: keyList{"c"},
+			CancelSplit:   keyList{"x"},
+		},
+	}
+}
+
+var activeConfig atomic.Pointer[Config]
+
+func init() {
+	activeConfig.Store(defaultConfig())
+}
+
+// currentConfig is the configuration in effect. It is replaced, never
+// modified, when the file is reloaded.
+func currentConfig() *Config {
+	return activeConfig.Load()
+}
+
+func setConfig(config *Config) {
+	activeConfig.Store(config)
+}
+
+// configPath is $XDG_CONFIG_HOME/romodoro/config.toml, falling back to
+// ~/.config.
+func configPath() (string, error) {
+	if path := os.Getenv("ROMODORO_CONFIG"); path != "" {
+		return path, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "romodoro", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "romodoro", "config.toml"), nil
+}
+
+// loadConfig reads and validates path. A missing file gives the defaults.
+func loadConfig(path string) (*Config, error) {
+	config := defaultConfig()
+
+	metadata, err := toml.DecodeFile(path, config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, key := range metadata.Undecoded() {
+		problems = append(problems, fmt.Sprintf("unknown setting %s", key))
+	}
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return config, nil
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func (c *Config) validate() []string {
+	var problems []string
+
+	if c.Timer.FocusMinutes <= 0 {
+		problems = append(problems, "timer.focus_minutes must be positive")
+	}
+	if c.Timer.RestMinutes < 0 {
+		problems = append(problems, "timer.rest_minutes must not be negative")
+	}
+	if c.Timer.LongBreakMinutes < 0 {
+		problems = append(problems, "timer.long_break_minutes must not be negative")
+	}
+	if c.Timer.ExtendMinutes <= 0 {
+		problems = append(problems, "timer.extend_minutes must be positive")
+	}
+
+	if c.Notifications.Enabled && len(c.Notifications.Backends) == 0 {
+		problems = append(problems, "notifications.backends is empty; set enabled = false to turn notifications off")
+	}
+	for _, backend := range c.Notifications.Backends {
+		if !slices.Contains(notifierNames, backend) {
+			problems = append(problems, fmt.Sprintf("notifications.backends: unknown notifier %q (use %s)",
+				backend, strings.Join(notifierNames, ", ")))
+		}
+		if backend == NotifierCommand && c.Notifications.Command == "" {
+			problems = append(problems, "notifications.command must be set to use the command notifier")
+		}
+	}
+
+	if c.Hooks.TimeoutSeconds <= 0 {
+		problems = append(problems, "hooks.timeout_seconds must be positive")
+	}
+	for _, event := range hookEvents {
+		if slices.Contains(c.Hooks.commands(event), "") {
+			problems = append(problems, fmt.Sprintf("hooks.%s: commands must not be empty", event))
+		}
+	}
+
+	urls := map[string]bool{}
+	for i, webhook := range c.Webhooks {
+		where := fmt.Sprintf("webhooks[%d]", i)
+		if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s.url: %q is not an http or https URL", where, webhook.URL))
+		} else if urls[webhook.URL] {
+			problems = append(problems, fmt.Sprintf("%s.url: %s is listed twice", where, webhook.URL))
+		}
+		urls[webhook.URL] = true
+		if webhook.Secret == "" {
+			problems = append(problems, fmt.Sprintf("%s.secret must be set; it signs every request", where))
+		}
+		for _, event := range webhook.Events {
+			if !slices.Contains(hookEvents, event) {
+				problems = append(problems, fmt.Sprintf("%s.events: unknown event %q (use %s)",
+					where, event, strings.Join(hookEvents, ", ")))
+			}
+		}
+	}
+
+	colors := map[string]string{
+		"accent": c.Theme.Accent, "text": c.Theme.Text, "muted": c.Theme.Muted,
+		"header": c.Theme.Header, "focus": c.Theme.Focus, "rest": c.Theme.Rest,
+		"paused": c.Theme.Paused, "progress_start": c.Theme.ProgressStart, "progress_end": c.Theme.ProgressEnd,
+	}
+	for _, name := range sortedKeys(colors) {
+		if !validColor(colors[name]) {
+			problems = append(problems, fmt.Sprintf("theme.%s: %q is neither a #RRGGBB color nor an ANSI color number", name, colors[name]))
+		}
+	}
+	if c.Theme.ProgressWidth <= 0 {
+		problems = append(problems, "theme.progress_width must be positive")
+	}
+
+	problems = append(problems, c.Keymap.validate()...)
+	return problems
+}
+
+func validColor(color string) bool {
+	if colorPattern.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// validate checks that every action has a key and that keys active on the
+// same screen do not clash.
+func (k KeymapConfig) validate() []string {
+	var problems []string
+
+	// Moving through lists is fixed to the arrows and j/k, and Enter picks
+	// a preset. The timer setup takes minutes typed as digits.
+	navigation := keyList{"up", "down", "j", "k"}
+	minutes := keyList{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "enter", "backspace"}
+	screens := []map[string]keyList{
+		// Main menu
+		{"quit": k.Quit, "continue_session": k.ContinueSession, "browse_sessions": k.BrowseSessions,
+			"new_session": k.NewSession, "attach": k.Attach},
+		// Timer setup
+		{"quit": k.Quit, "minutes": minutes, "menu": k.Menu},
+		// Timer
+		{"quit": k.Quit, "pause": k.Pause, "skip": k.Skip, "extend": k.Extend, "restart": k.Restart,
+			"finish": k.Finish, "acknowledge": k.Acknowledge, "soft_end": k.SoftEnd,
+			"auto_continue": k.AutoContinue, "back": k.Back, "menu": k.Menu},
+		// Paused
+		{"quit": k.Quit, "resume": k.Resume, "back": k.Back, "menu": k.Menu,
+			"internal": k.Internal, "external": k.External, "reason": k.Reason},
+		// Session browser
+		{"quit": k.Quit, "navigation": navigation, "export": k.Export, "delete": k.Delete,
+			"back": k.Back, "menu": k.Menu},
+		// Preset picker
+		{"quit": k.Quit, "navigation": append(navigation, "enter"), "custom": k.Custom,
+			"flowtime": k.Flowtime, "auto_continue": k.AutoContinue, "delete": k.Delete, "menu": k.Menu},
+		// Attached to the daemon
+		{"quit": k.Quit, "pause": k.Pause, "resume": k.Resume, "skip": k.Skip, "stop": k.Stop,
+			"detach": k.Detach, "menu": k.Menu},
+		// Auto-continue countdown
+		{"quit": k.Quit, "start_next": k.StartNext, "cancel_next": k.CancelNext, "menu": k.Menu},
+		// Unfinished split
+		{"quit": k.Quit, "resume_split": k.ResumeSplit, "complete_split": k.CompleteSplit,
+			"cancel_split": k.CancelSplit},
+	}
+	for _, actions := range screens {
+		owner := make(map[string]string)
+		for _, action := range sortedKeys(actions) {
+			keys := actions[action]
+			if len(keys) == 0 {
+				problems = append(problems, fmt.Sprintf("keymap.%s has no keys", action))
+			}
+			for _, key := range keys {
+				normalized := key
+				if len(key) == 1 {
+					normalized = strings.ToLower(key)
+				}
+				if other, taken := owner[normalized]; taken && other != action {
+					problems = append(problems, fmt.Sprintf("keymap: %q is bound to both %s and %s", key, other, action))
+				}
+				owner[normalized] = action
+			}
+		}
+	}
+	return dedupe(problems)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func dedupe(items []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// configWatcher notices when the config file changes on disk.
+type configWatcher struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func newConfigWatcher(path string) *configWatcher {
+	w := &configWatcher{path: path}
+	w.changed()
+	return w
+}
+
+// changed reports whether the file was written, created or removed since
+// the last call.
+func (w *configWatcher) changed() bool {
+	var modTime time.Time
+	var size int64 = -1
+	if info, err := os.Stat(w.path); err == nil {
+		modTime = info.ModTime()
+		size = info.Size()
+	}
+	if modTime.Equal(w.modTime) && size == w.size {
+		return false
+	}
+	w.modTime = modTime
+	w.size = size
+	return true
+}
+
+type configCheckMsg struct{}
+
+const configCheckInterval = 2 * time.Second
+
+func configCheckCmd() tea.Cmd {
+	return tea.Tick(configCheckInterval, func(time.Time) tea.Msg {
+		return configCheckMsg{}
+	})
+}
+
+// updateConfigCheck reloads the config file when it changed. A broken file
+// leaves the previous settings in place.
+func (m *App) updateConfigCheck() (tea.Model, tea.Cmd) {
+	if m.configWatcher == nil || !m.configWatcher.changed() {
+		return m, configCheckCmd()
+	}
+
+	config, err := loadConfig(m.configWatcher.path)
+	if err != nil {
+		m.notice = "Config not reloaded: " + err.Error()
+		return m, configCheckCmd()
+	}
+	setConfig(config)
+	m.applyConfig()
+	m.notice = "Config reloaded."
+	return m, configCheckCmd()
+}
+
+// applyConfig pushes the settings that live in UI components.
+func (m *App) applyConfig() {
+	theme := currentConfig().Theme
+	applyTheme(theme)
+
+	m.progress = progress.New(progress.WithGradient(theme.ProgressStart, theme.ProgressEnd))
+	m.progress.Width = theme.ProgressWidth
+	if m.width > 0 {
+		m.progress.Width = min(theme.ProgressWidth, m.width-4)
+	}
+	if m.state == StateTimerSetup && m.inputStep == 0 {
+		m.textInput.Placeholder = fmt.Sprintf("Enter focus time in minutes (Enter for %d)...", currentConfig().Timer.FocusMinutes)
+	}
+}
+
+func cmdConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "validate":
+		if len(args) > 2 {
+			fmt.Fprintln(stderr, "romodoro config validate: expected at most one path")
+			return ExitUsage
+		}
+		path, err := configPath()
+		if err != nil {
+			fmt.Fprintln(stderr, "romodoro config validate:", err)
+			return ExitError
+		}
+		if len(args) == 2 {
+			path = args[1]
+		} else if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(stdout, "No config file at %s; the defaults are in use\n", path)
+			return ExitOK
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(stderr, "romodoro config validate:", err)
+			return ExitBadConfig
+		}
+		if _, err := loadConfig(path); err != nil {
+			fmt.Fprintln(stderr, "romodoro config validate:", err)
+			return ExitBadConfig
+		}
+		fmt.Fprintf(stdout, "%s is valid\n", path)
+		return ExitOK
+	case "path":
+		path, err := configPath()
+		if err != nil {
+			fmt.Fprintln(stderr, "romodoro config path:", err)
+			return ExitError
+		}
+		fmt.Fprintln(stdout, path)
+		return ExitOK
+	case "default":
+		if err := toml.NewEncoder(stdout).Encode(defaultConfig()); err != nil {
+			fmt.Fprintln(stderr, "romodoro config default:", err)
+			return ExitError
+		}
+		return ExitOK
+	}
+
+	fmt.Fprintf(stderr, "romodoro config: unknown command %q\n\n%s", args[0], usage)
+	return ExitUsage
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"romodoro/timer"
+)
+
+// daemon owns a timer and the store so a split keeps running with no
+// terminal attached. It runs one split at a time; cycles and auto-continue
+// stay a TUI feature.
+type daemon struct {
+	store  Store
+	stderr io.Writer
+
+	mu           sync.Mutex
+	timer        *timer.Timer
+	session      *Session
+	split        *PomodoroSplit
+	interruption *Interruption
+	splitNumber  int
+	subscribers  map[chan TimerStatus]struct{}
+
+	// hooksFrom is when an adopted split was picked up. Earlier events
+	// were reported by whoever was timing the split then.
+	hooksFrom time.Time
+}
+
+func newDaemon(store Store, stderr io.Writer) *daemon {
+	return &daemon{
+		store:       store,
+		stderr:      stderr,
+		timer:       timer.New(timer.SystemClock{}),
+		subscribers: make(map[chan TimerStatus]struct{}),
+	}
+}
+
+func cmdDaemon(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	path := flags.String("socket", socketPath(), "Unix socket `path` to listen on")
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	listener, err := listenSocket(*path)
+	if errors.Is(err, errDaemonRunning) {
+		fmt.Fprintf(stderr, "romodoro daemon: already running on %s\n", *path)
+		return ExitRunning
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro daemon:", err)
+		return ExitError
+	}
+	defer os.Remove(*path)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(stdout, "Listening on %s\n", *path)
+	if err := runDaemon(ctx, store, listener, stderr); err != nil {
+		fmt.Fprintln(stderr, "romodoro daemon:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// runDaemon picks up any running split and answers on listener until ctx
+// is done. A split still running then stays in progress, to be picked up
+// by the next daemon or the TUI.
+func runDaemon(ctx context.Context, store Store, listener net.Listener, stderr io.Writer) error {
+	d := newDaemon(store, stderr)
+	if err := d.adopt(); err != nil {
+		listener.Close()
+		return err
+	}
+	defer d.release()
+
+	go d.run(ctx, stderr)
+	go runWebhooks(ctx, store)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go d.serve(conn)
+	}
+}
+
+var errDaemonRunning = errors.New("daemon already running")
+
+// listenSocket listens on path, clearing a socket left behind by a daemon
+// that did not shut down cleanly.
+func listenSocket(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errDaemonRunning
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// adopt resumes a split that was started from the command line or left
+// running by a process that has exited, paused if it was paused. Splits
+// whose time ran out meanwhile are completed, and splits that the TUI or
+// another daemon is still timing are left alone.
+func (d *daemon) adopt() error {
+	now := time.Now()
+	splits, err := d.store.GetInProgressSplits()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range splits {
+		split := &splits[i]
+		claimed, err := claimSplit(d.store, split.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		session, err := d.store.GetSession(split.SessionID)
+		if err != nil {
+			return err
+		}
+
+		d.hooksFrom = now
+		d.interruption, err = d.begin(session, split)
+		if err != nil {
+			return err
+		}
+		d.timer.Tick()
+		d.handleEvents()
+		if d.split != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// release gives up a split that is still running as the daemon stops.
+func (d *daemon) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.split != nil {
+		releaseSplit(d.store, d.split.ID)
+	}
+}
+
+// begin takes split up where it stands, which for a new split is its
+// start. It returns the open interruption of a paused split.
+func (d *daemon) begin(session *Session, split *PomodoroSplit) (*Interruption, error) {
+	d.session = session
+	d.split = split
+	d.splitNumber = splitNumber(d.store, split)
+	return replaySplit(d.store, d.timer, split, session)
+}
+
+// run ticks the timer and keeps subscribers up to date. It also picks up
+// changes to the config file.
+func (d *daemon) run(ctx context.Context, stderr io.Writer) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var watcher *configWatcher
+	if path, err := configPath(); err == nil {
+		watcher = newConfigWatcher(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if watcher != nil && watcher.changed() {
+				if config, err := loadConfig(watcher.path); err != nil {
+					fmt.Fprintln(stderr, "romodoro daemon: config not reloaded:", err)
+				} else {
+					setConfig(config)
+				}
+			}
+
+			d.mu.Lock()
+			d.timer.Tick()
+			d.handleEvents()
+			d.broadcast()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// notify sends the notification in the background so a slow backend
+// cannot hold up the timer.
+func (d *daemon) notify(event timer.Event) {
+	go func() {
+		if err := sendNotification(notificationFor(event)); err != nil {
+			fmt.Fprintln(d.stderr, "romodoro daemon: notification failed:", err)
+		}
+	}()
+}
+
+// handleEvents applies what the timer reported, as App.drainTimerEvents
+// does for the TUI. Callers hold d.mu.
+func (d *daemon) handleEvents() {
+	for {
+		select {
+		case event := <-d.timer.Events():
+			split := d.split
+			// Whoever timed the split before us reported older events
+			fresh := !event.At.Before(d.hooksFrom)
+			switch event.Type {
+			case timer.PhaseStarted:
+				if event.Phase == timer.Rest && d.split.Mode == SplitModeFlowtime {
+					d.split.RestMinutes = int(d.timer.Duration().Minutes())
+					d.store.UpdatePomodoroSplit(d.split)
+				}
+			case timer.PhaseOvertime:
+				if fresh {
+					d.notify(event)
+				}
+			case timer.PhaseEnded:
+				if fresh {
+					d.notify(event)
+				}
+				if event.Phase == timer.Rest {
+					d.finish(event.At, "completed")
+				}
+			}
+			if fresh {
+				emitEvent(d.store, hookForEvent(event), d.session, split)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (d *daemon) finish(endTime time.Time, status string) {
+	d.endInterruption()
+	d.split.EndTime = &endTime
+	d.split.Status = status
+	recordElapsed(d.split, d.timer)
+	d.store.UpdatePomodoroSplit(d.split)
+	d.store.UpdateSessionTotals(d.split.SessionID)
+	d.split = nil
+}
+
+func (d *daemon) endInterruption() {
+	if d.interruption == nil {
+		return
+	}
+	now := time.Now()
+	d.interruption.EndedAt = &now
+	d.interruption.DurationSeconds = int(now.Sub(d.interruption.StartedAt).Seconds())
+	d.store.UpdateInterruption(d.interruption)
+	d.interruption = nil
+}
+
+// status snapshots the timer. Callers hold d.mu.
+func (d *daemon) status() TimerStatus {
+	if d.split == nil {
+		return TimerStatus{State: "idle"}
+	}
+	status := TimerStatus{
+		State:            d.timer.State().String(),
+		Phase:            d.timer.Phase().String(),
+		RemainingSeconds: int(d.timer.Remaining().Round(time.Second).Seconds()),
+		ElapsedSeconds:   int(d.timer.Elapsed().Seconds()),
+		CountingUp:       d.timer.CountingUp(),
+		SessionID:        d.session.ID,
+		SessionName:      d.session.Name,
+		SplitID:          d.split.ID,
+		SplitNumber:      d.splitNumber,
+	}
+	if status.CountingUp {
+		status.RemainingSeconds = 0
+	}
+	return status
+}
+
+func (d *daemon) subscribe() chan TimerStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	updates := make(chan TimerStatus, 8)
+	d.subscribers[updates] = struct{}{}
+	return updates
+}
+
+func (d *daemon) unsubscribe(updates chan TimerStatus) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subscribers, updates)
+	close(updates)
+}
+
+// broadcast sends the current status to every subscriber, dropping it for
+// those that have fallen behind. Callers hold d.mu.
+func (d *daemon) broadcast() {
+	status := d.status()
+	for updates := range d.subscribers {
+		select {
+		case updates <- status:
+		default:
+		}
+	}
+}
+
+var errNotRunning = &rpcError{Code: ExitNotRunning, Message: "no split is running"}
+
+// call runs one method. Every method answers with the status after it ran.
+func (d *daemon) call(method string, params json.RawMessage) (TimerStatus, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var err error
+	switch method {
+	case "status", "subscribe":
+	case "start":
+		err = d.start(params)
+	case "pause":
+		err = d.pause()
+	case "resume":
+		err = d.resume()
+	case "skip":
+		err = d.skip()
+	case "stop":
+		err = d.stop()
+	default:
+		return TimerStatus{}, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+	}
+	if err != nil {
+		return TimerStatus{}, err
+	}
+
+	if method != "status" && method != "subscribe" {
+		d.broadcast()
+	}
+	return d.status(), nil
+}
+
+func (d *daemon) start(raw json.RawMessage) error {
+	defaults := currentConfig().Timer
+	params := startParams{Focus: defaults.FocusMinutes, Rest: defaults.RestMinutes}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	if !params.Flowtime && (params.Focus <= 0 || params.Rest < 0) {
+		return &rpcError{Code: rpcInvalidParams, Message: "focus must be positive and rest must not be negative"}
+	}
+	if d.split != nil {
+		return &rpcError{Code: ExitRunning, Message: fmt.Sprintf("split %d is already running", d.split.ID)}
+	}
+
+	// The TUI may be timing a split of its own
+	now := time.Now()
+	running, _, err := runningSplit(d.store, now)
+	if err != nil {
+		return err
+	}
+	if running != nil {
+		return &rpcError{Code: ExitRunning, Message: fmt.Sprintf("split %d is already running in another romodoro process", running.ID)}
+	}
+
+	session, err := findOrCreateSession(d.store, params.Session, now)
+	if err != nil {
+		return err
+	}
+	split := PomodoroSplit{
+		SessionID:    session.ID,
+		FocusMinutes: params.Focus,
+		RestMinutes:  params.Rest,
+		Mode:         SplitModeCountdown,
+	}
+	if params.Flowtime {
+		split.FocusMinutes = 0
+		split.RestMinutes = 0
+		split.Mode = SplitModeFlowtime
+	}
+	created, err := d.store.CreatePomodoroSplit(split)
+	if err != nil {
+		return err
+	}
+	if _, err := claimSplit(d.store, created.ID); err != nil {
+		return err
+	}
+
+	d.hooksFrom = time.Time{}
+	if _, err := d.begin(session, created); err != nil {
+		return err
+	}
+	d.handleEvents()
+	return nil
+}
+
+func (d *daemon) pause() error {
+	if d.split == nil || d.timer.State() != timer.StateRunning {
+		return errNotRunning
+	}
+	d.timer.Pause()
+	d.handleEvents()
+
+	interruption, err := d.store.CreateInterruption(Interruption{
+		SplitID:   d.split.ID,
+		Phase:     d.timer.Phase().String(),
+		StartedAt: wallNow(),
+	})
+	if err == nil {
+		d.interruption = interruption
+	}
+	return nil
+}
+
+func (d *daemon) resume() error {
+	if d.split == nil || d.timer.State() != timer.StatePaused {
+		return &rpcError{Code: ExitNotRunning, Message: "no split is paused"}
+	}
+	d.endInterruption()
+	d.timer.Resume()
+	d.handleEvents()
+	return nil
+}
+
+func (d *daemon) skip() error {
+	if d.split == nil {
+		return errNotRunning
+	}
+	now := wallNow()
+	d.timer.TickAt(now)
+	if d.timer.Phase() == timer.Focus {
+		d.split.FocusSkipped = true
+	} else {
+		d.split.RestSkipped = true
+	}
+	d.store.UpdatePomodoroSplit(d.split)
+
+	d.timer.EndPhaseAt(now)
+	recordSplitEvent(d.store, d.split.ID, SplitEventEnded, now, 0)
+	d.handleEvents()
+	return nil
+}
+
+func (d *daemon) stop() error {
+	if d.split == nil {
+		return errNotRunning
+	}
+	d.timer.Cancel()
+	d.handleEvents()
+	split := d.split
+	d.finish(time.Now(), "cancelled")
+	emitEvent(d.store, HookSplitCancelled, d.session, split)
+	return nil
+}
+
+// serve answers requests on one connection until the client hangs up.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	encoder := json.NewEncoder(conn)
+	send := func(message rpcMessage) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		message.JSONRPC = "2.0"
+		encoder.Encode(message)
+	}
+
+	var updates chan TimerStatus
+	defer func() {
+		if updates != nil {
+			d.unsubscribe(updates)
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var request rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			send(rpcMessage{Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+
+		if request.Method == "subscribe" && updates == nil {
+			updates = d.subscribe()
+			go func(updates chan TimerStatus) {
+				for status := range updates {
+					params, _ := json.Marshal(status)
+					send(rpcMessage{Method: "status", Params: params})
+				}
+			}(updates)
+		}
+
+		status, err := d.call(request.Method, request.Params)
+		if err != nil {
+			var rpcErr *rpcError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpcError{Code: ExitError, Message: err.Error()}
+			}
+			send(rpcMessage{ID: request.ID, Error: rpcErr})
+			continue
+		}
+		result, _ := json.Marshal(status)
+		send(rpcMessage{ID: request.ID, Result: result})
+	}
+}
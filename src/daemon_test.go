@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDaemonRPC(t *testing.T) {
+	steps := []struct {
+		method string
+		params any
+		code   int // 0 for success, else the rpcError code
+		state  string
+		phase  string
+	}{
+		{method: "status", state: "idle"},
+		{method: "pause", code: ExitNotRunning},
+		{method: "skip", code: ExitNotRunning},
+		{method: "start", params: startParams{Focus: 0, Rest: 5}, code: rpcInvalidParams},
+		{method: "start", params: json.RawMessage(`"25"`), code: rpcInvalidParams},
+		{method: "start", params: startParams{Focus: 25, Rest: 5, Session: "Daemon"}, state: "running", phase: "focus"},
+		{method: "start", params: startParams{Focus: 25, Rest: 5}, code: ExitRunning},
+		{method: "pause", state: "paused", phase: "focus"},
+		{method: "pause", code: ExitNotRunning},
+		{method: "resume", state: "running", phase: "focus"},
+		{method: "resume", code: ExitNotRunning},
+		{method: "skip", state: "running", phase: "rest"},
+		{method: "status", state: "running", phase: "rest"},
+		{method: "bogus", code: rpcMethodNotFound},
+		{method: "stop", state: "idle"},
+		{method: "stop", code: ExitNotRunning},
+	}
+
+	forEachStore(t, func(t *testing.T, store Store) {
+		startTestDaemon(t, store)
+
+		for i, step := range steps {
+			status, ok, err := callDaemon(step.method, step.params)
+			if !ok {
+				t.Fatalf("step %d: no daemon", i)
+			}
+			if step.code != 0 {
+				var rpcErr *rpcError
+				if !errors.As(err, &rpcErr) || rpcErr.Code != step.code {
+					t.Errorf("step %d, %s: error %v, want code %d", i, step.method, err, step.code)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("step %d, %s: %v", i, step.method, err)
+			}
+			if status.State != step.state || status.Phase != step.phase {
+				t.Errorf("step %d, %s: got %q %q, want %q %q", i, step.method, status.State, status.Phase, step.state, step.phase)
+			}
+			if step.state != "idle" && (status.SessionName != "Daemon" || status.SplitNumber != 1) {
+				t.Errorf("step %d, %s: split %d of %q, want split 1 of \"Daemon\"", i, step.method, status.SplitNumber, status.SessionName)
+			}
+		}
+
+		splits, err := store.GetAllSplits()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(splits) != 1 {
+			t.Fatalf("%d splits, want 1", len(splits))
+		}
+		split := splits[0]
+		if split.Status != "cancelled" || !split.FocusSkipped || split.EndTime == nil {
+			t.Errorf("split = %+v, want cancelled after skipping focus", split)
+		}
+		interruptions, err := store.GetSplitInterruptions(split.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(interruptions) != 1 || interruptions[0].EndedAt == nil {
+			t.Errorf("interruptions %+v, want the one closed pause", interruptions)
+		}
+	})
+}
+
+// The TUI claims the splits it times, and the daemon must neither adopt
+// them nor start a second one next to them.
+func TestDaemonStartRefusesSplitTimedElsewhere(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		// The parent process stands in for a TUI that is still running
+		tui := os.Getppid()
+		split := startTestSplit(t, store, PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}, true)
+		if _, err := store.SetSplitOwner(split.ID, 0, tui); err != nil {
+			t.Fatal(err)
+		}
+		startTestDaemon(t, store)
+
+		_, _, err := callDaemon("start", startParams{Focus: 25, Rest: 5})
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != ExitRunning {
+			t.Fatalf("start: %v, want code %d", err, ExitRunning)
+		}
+		if splits, err := store.GetAllSplits(); err != nil || len(splits) != 1 {
+			t.Errorf("%d splits, %v; want only the TUI's", len(splits), err)
+		}
+		if owner, err := store.GetSplitOwner(split.ID); err != nil || owner != tui {
+			t.Errorf("owner %d, %v; want the TUI, %d", owner, err, tui)
+		}
+
+		// Once the TUI lets go of it, nothing is timing the soft-end split
+		if _, err := store.SetSplitOwner(split.ID, tui, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := callDaemon("start", startParams{Focus: 25, Rest: 5}); err != nil {
+			t.Errorf("start after the release: %v", err)
+		}
+	})
+}
@@ -8,72 +8,143 @@ import (
 )
 
 type Session struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   *time.Time `json:"end_time"`
-	TotalFocusSeconds int `json:"total_focus_seconds"`
-	TotalRestSeconds  int `json:"total_rest_seconds"`
+	ID                       int        `json:"id"`
+	Name                     string     `json:"name"`
+	StartTime                time.Time  `json:"start_time"`
+	EndTime                  *time.Time `json:"end_time"`
+	TotalFocusSeconds        int        `json:"total_focus_seconds"`
+	TotalRestSeconds         int        `json:"total_rest_seconds"`
+	AutoContinue             bool       `json:"auto_continue"`
+	AutoContinueGraceSeconds int        `json:"auto_continue_grace_seconds"`
+	SoftEnd                  bool       `json:"soft_end"`
+	InterruptionCount        int        `json:"interruption_count"`
 }
 
 type PomodoroSplit struct {
-	ID              int       `json:"id"`
-	SessionID       int       `json:"session_id"`
-	FocusMinutes    int       `json:"focus_minutes"`
-	RestMinutes     int       `json:"rest_minutes"`
-	StartTime       time.Time `json:"start_time"`
-	EndTime         *time.Time `json:"end_time"`
-	Status          string    `json:"status"` // "completed", "cancelled", "in_progress"
-	ActualFocusSeconds int    `json:"actual_focus_seconds"`
-	ActualRestSeconds  int    `json:"actual_rest_seconds"`
+	ID                 int        `json:"id"`
+	SessionID          int        `json:"session_id"`
+	FocusMinutes       int        `json:"focus_minutes"`
+	RestMinutes        int        `json:"rest_minutes"`
+	StartTime          time.Time  `json:"start_time"`
+	EndTime            *time.Time `json:"end_time"`
+	Status             string     `json:"status"` // "completed", "cancelled", "in_progress"
+	ActualFocusSeconds int        `json:"actual_focus_seconds"`
+	ActualRestSeconds  int        `json:"actual_rest_seconds"`
+	CyclePosition      int        `json:"cycle_position"` // 1-based; 0 when not part of a cycle
+	CycleLength        int        `json:"cycle_length"`
+	Mode               string     `json:"mode"` // "countdown", "flowtime"
+
+	// Adjustments made while the split ran. Planned minutes above never
+	// include extensions.
+	FocusExtensionSeconds int  `json:"focus_extension_seconds"`
+	RestExtensionSeconds  int  `json:"rest_extension_seconds"`
+	FocusSkipped          bool `json:"focus_skipped"`
+	RestSkipped           bool `json:"rest_skipped"`
+	Restarts              int  `json:"restarts"`
+
+	// Time spent past the deadline of a soft-end phase. It is already
+	// included in the actual seconds.
+	FocusOvertimeSeconds int `json:"focus_overtime_seconds"`
+	RestOvertimeSeconds  int `json:"rest_overtime_seconds"`
 }
 
-func InitDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
-	}
+const (
+	SplitModeCountdown = "countdown"
+	SplitModeFlowtime  = "flowtime"
+)
 
-	// Create sessions table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS sessions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			start_time DATETIME NOT NULL,
-			end_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			total_focus_seconds INTEGER DEFAULT 0,
-			total_rest_seconds INTEGER DEFAULT 0
-		)
-	`)
+// Interruption is one pause of a running split, with an optional reason.
+type Interruption struct {
+	ID              int        `json:"id"`
+	SplitID         int        `json:"split_id"`
+	Phase           string     `json:"phase"` // "focus", "rest"
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int        `json:"duration_seconds"`
+	Kind            string     `json:"kind"` // "", "internal", "external"
+	Reason          string     `json:"reason"`
+}
+
+const (
+	InterruptionInternal = "internal"
+	InterruptionExternal = "external"
+)
+
+// SplitEvent is a change made to a running split's timer. Together with
+// the interruptions they let any process rebuild the timer exactly.
+type SplitEvent struct {
+	ID      int       `json:"id"`
+	SplitID int       `json:"split_id"`
+	Kind    string    `json:"kind"`
+	At      time.Time `json:"at"`
+	Seconds int       `json:"seconds"` // the extension, for SplitEventExtended
+}
+
+const (
+	SplitEventExtended   = "extended"
+	SplitEventRestarted  = "restarted"
+	SplitEventEnded      = "ended" // skipped, acknowledged or finished early
+	SplitEventSoftEndOn  = "soft_end_on"
+	SplitEventSoftEndOff = "soft_end_off"
+)
+
+// WebhookDelivery is one POST waiting in the outbox. Payload is the body
+// as it will be sent.
+type WebhookDelivery struct {
+	ID            int       `json:"id"`
+	URL           string    `json:"url"`
+	Event         string    `json:"event"`
+	Payload       string    `json:"payload"`
+	CreatedAt     time.Time `json:"created_at"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+}
+
+type Preset struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	FocusMinutes     int    `json:"focus_minutes"`
+	RestMinutes      int    `json:"rest_minutes"`
+	LongBreakMinutes int    `json:"long_break_minutes"`
+	CycleLength      int    `json:"cycle_length"`
+	BuiltIn          bool   `json:"builtin"`
+
+	AutoContinue             bool `json:"auto_continue"`
+	AutoContinueGraceSeconds int  `json:"auto_continue_grace_seconds"`
+}
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// sqliteOptions let the TUI, the daemon and the command line share the
+// database: writers wait up to five seconds for one another, readers never
+// block writers, and transactions take the write lock up front so one that
+// reads before it writes cannot fail on upgrading its lock.
+const sqliteOptions = "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", dbPath+sqliteOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create pomodoro_splits table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS pomodoro_splits (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			session_id INTEGER NOT NULL,
-			focus_minutes INTEGER NOT NULL,
-			rest_minutes INTEGER NOT NULL,
-			start_time DATETIME NOT NULL,
-			end_time DATETIME,
-			status TEXT NOT NULL DEFAULT 'in_progress',
-			actual_focus_seconds INTEGER DEFAULT 0,
-			actual_rest_seconds INTEGER DEFAULT 0,
-			FOREIGN KEY (session_id) REFERENCES sessions (id)
-		)
-	`)
-	if err != nil {
+	if err := migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return db, nil
+	return &SQLiteStore{db: db}, nil
 }
 
-func CreateSession(db *sql.DB, name string) (*Session, error) {
-	now := time.Now()
-	result, err := db.Exec(
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) CreateSession(name string) (*Session, error) {
+	now := wallNow()
+	result, err := s.db.Exec(
 		"INSERT INTO sessions (name, start_time, end_time) VALUES (?, ?, ?)",
 		name, now, now,
 	)
@@ -95,18 +166,30 @@ func CreateSession(db *sql.DB, name string) (*Session, error) {
 	}, nil
 }
 
-func GetLastSession(db *sql.DB) (*Session, error) {
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const sessionColumns = `id, name, start_time, end_time, total_focus_seconds, total_rest_seconds,
+	auto_continue, auto_continue_grace_seconds, soft_end,
+	(
+		SELECT COUNT(*)
+		FROM interruptions
+		JOIN pomodoro_splits ON pomodoro_splits.id = interruptions.split_id
+		WHERE pomodoro_splits.session_id = sessions.id
+	)`
+
+func scanSession(row scanner) (*Session, error) {
 	var session Session
 	var endTime time.Time
 
-	err := db.QueryRow(`
-		SELECT id, name, start_time, end_time, total_focus_seconds, total_rest_seconds
-		FROM sessions
-		ORDER BY start_time DESC
-		LIMIT 1
-	`).Scan(&session.ID, &session.Name, &session.StartTime, &endTime,
-		&session.TotalFocusSeconds, &session.TotalRestSeconds)
-
+	err := row.Scan(&session.ID, &session.Name, &session.StartTime, &endTime,
+		&session.TotalFocusSeconds, &session.TotalRestSeconds,
+		&session.AutoContinue, &session.AutoContinueGraceSeconds, &session.SoftEnd, &session.InterruptionCount)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -115,9 +198,26 @@ func GetLastSession(db *sql.DB) (*Session, error) {
 	return &session, nil
 }
 
-func GetAllSessions(db *sql.DB) ([]Session, error) {
-	rows, err := db.Query(`
-		SELECT id, name, start_time, end_time, total_focus_seconds, total_rest_seconds
+func (s *SQLiteStore) GetLastSession() (*Session, error) {
+	return scanSession(s.db.QueryRow(`
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		ORDER BY start_time DESC
+		LIMIT 1
+	`))
+}
+
+func (s *SQLiteStore) GetSession(sessionID int) (*Session, error) {
+	return scanSession(s.db.QueryRow(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE id = ?
+	`, sessionID))
+}
+
+func (s *SQLiteStore) GetAllSessions() ([]Session, error) {
+	rows, err := s.db.Query(`
+		SELECT ` + sessionColumns + `
 		FROM sessions
 		ORDER BY start_time DESC
 	`)
@@ -128,40 +228,62 @@ func GetAllSessions(db *sql.DB) ([]Session, error) {
 
 	var sessions []Session
 	for rows.Next() {
-		var session Session
-		var endTime time.Time
-
-		err := rows.Scan(&session.ID, &session.Name, &session.StartTime, &endTime,
-			&session.TotalFocusSeconds, &session.TotalRestSeconds)
+		session, err := scanSession(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		session.EndTime = &endTime
-		sessions = append(sessions, session)
+		sessions = append(sessions, *session)
 	}
 
-	return sessions, nil
+	return sessions, rows.Err()
+}
+
+func (s *SQLiteStore) SetSessionSoftEnd(sessionID int, enabled bool) error {
+	_, err := s.db.Exec("UPDATE sessions SET soft_end = ? WHERE id = ?", enabled, sessionID)
+	return err
 }
 
-func DeleteSession(db *sql.DB, sessionID int) error {
-	// Delete pomodoro splits first (foreign key constraint)
-	_, err := db.Exec("DELETE FROM pomodoro_splits WHERE session_id = ?", sessionID)
+func (s *SQLiteStore) SetSessionAutoContinue(sessionID int, enabled bool, graceSeconds int) error {
+	_, err := s.db.Exec(
+		"UPDATE sessions SET auto_continue = ?, auto_continue_grace_seconds = ? WHERE id = ?",
+		enabled, graceSeconds, sessionID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteSession(sessionID int) error {
+	// Delete interruptions, split events and pomodoro splits first
+	// (foreign key constraints)
+	for _, table := range []string{"interruptions", "split_events"} {
+		_, err := s.db.Exec(`
+			DELETE FROM `+table+`
+			WHERE split_id IN (SELECT id FROM pomodoro_splits WHERE session_id = ?)
+		`, sessionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec("DELETE FROM pomodoro_splits WHERE session_id = ?", sessionID)
 	if err != nil {
 		return err
 	}
 
 	// Delete session
-	_, err = db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
+	_, err = s.db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
 	return err
 }
 
-func CreatePomodoroSplit(db *sql.DB, sessionID, focusMinutes, restMinutes int) (*PomodoroSplit, error) {
-	now := time.Now()
-	result, err := db.Exec(`
-		INSERT INTO pomodoro_splits (session_id, focus_minutes, rest_minutes, start_time)
-		VALUES (?, ?, ?, ?)
-	`, sessionID, focusMinutes, restMinutes, now)
+// CreatePomodoroSplit stores a new in-progress split starting now. The ID,
+// StartTime and Status of the argument are ignored.
+func (s *SQLiteStore) CreatePomodoroSplit(split PomodoroSplit) (*PomodoroSplit, error) {
+	now := wallNow()
+	result, err := s.db.Exec(`
+		INSERT INTO pomodoro_splits (session_id, focus_minutes, rest_minutes, start_time,
+			cycle_position, cycle_length, mode)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, split.SessionID, split.FocusMinutes, split.RestMinutes, now,
+		split.CyclePosition, split.CycleLength, split.Mode)
 
 	if err != nil {
 		return nil, err
@@ -172,29 +294,122 @@ func CreatePomodoroSplit(db *sql.DB, sessionID, focusMinutes, restMinutes int) (
 		return nil, err
 	}
 
-	return &PomodoroSplit{
-		ID:           int(id),
-		SessionID:    sessionID,
-		FocusMinutes: focusMinutes,
-		RestMinutes:  restMinutes,
-		StartTime:    now,
-		Status:       "in_progress",
-	}, nil
+	split.ID = int(id)
+	split.StartTime = now
+	split.EndTime = nil
+	split.Status = "in_progress"
+	if split.Mode == "" {
+		split.Mode = SplitModeCountdown
+	}
+	return &split, nil
 }
 
-func UpdatePomodoroSplit(db *sql.DB, split *PomodoroSplit) error {
-	_, err := db.Exec(`
+func (s *SQLiteStore) UpdatePomodoroSplit(split *PomodoroSplit) error {
+	_, err := s.db.Exec(`
 		UPDATE pomodoro_splits
-		SET end_time = ?, status = ?, actual_focus_seconds = ?, actual_rest_seconds = ?
+		SET end_time = ?, status = ?, rest_minutes = ?, actual_focus_seconds = ?, actual_rest_seconds = ?,
+			focus_extension_seconds = ?, rest_extension_seconds = ?,
+			focus_skipped = ?, rest_skipped = ?, restarts = ?,
+			focus_overtime_seconds = ?, rest_overtime_seconds = ?
 		WHERE id = ?
-	`, split.EndTime, split.Status, split.ActualFocusSeconds, split.ActualRestSeconds, split.ID)
+	`, split.EndTime, split.Status, split.RestMinutes, split.ActualFocusSeconds, split.ActualRestSeconds,
+		split.FocusExtensionSeconds, split.RestExtensionSeconds,
+		split.FocusSkipped, split.RestSkipped, split.Restarts,
+		split.FocusOvertimeSeconds, split.RestOvertimeSeconds, split.ID)
 
 	return err
 }
 
-func UpdateSessionTotals(db *sql.DB, sessionID int) error {
+const splitColumns = `id, session_id, focus_minutes, rest_minutes, start_time, end_time,
+	status, actual_focus_seconds, actual_rest_seconds, cycle_position, cycle_length, mode,
+	focus_extension_seconds, rest_extension_seconds, focus_skipped, rest_skipped, restarts,
+	focus_overtime_seconds, rest_overtime_seconds`
+
+func scanSplit(row scanner) (PomodoroSplit, error) {
+	var split PomodoroSplit
+	var endTime sql.NullTime
+
+	err := row.Scan(&split.ID, &split.SessionID, &split.FocusMinutes, &split.RestMinutes,
+		&split.StartTime, &endTime, &split.Status,
+		&split.ActualFocusSeconds, &split.ActualRestSeconds,
+		&split.CyclePosition, &split.CycleLength, &split.Mode,
+		&split.FocusExtensionSeconds, &split.RestExtensionSeconds,
+		&split.FocusSkipped, &split.RestSkipped, &split.Restarts,
+		&split.FocusOvertimeSeconds, &split.RestOvertimeSeconds)
+	if err != nil {
+		return split, err
+	}
+
+	if endTime.Valid {
+		split.EndTime = &endTime.Time
+	}
+	return split, nil
+}
+
+func (s *SQLiteStore) GetSessionSplits(sessionID int) ([]PomodoroSplit, error) {
+	return s.querySplits(`WHERE session_id = ?`, sessionID)
+}
+
+func (s *SQLiteStore) GetInProgressSplits() ([]PomodoroSplit, error) {
+	return s.querySplits(`WHERE status = 'in_progress'`)
+}
+
+func (s *SQLiteStore) GetAllSplits() ([]PomodoroSplit, error) {
+	return s.querySplits(``)
+}
+
+// querySplits returns the splits matching where, oldest first.
+func (s *SQLiteStore) querySplits(where string, args ...any) ([]PomodoroSplit, error) {
+	rows, err := s.db.Query(`
+		SELECT `+splitColumns+`
+		FROM pomodoro_splits
+		`+where+`
+		ORDER BY start_time ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var splits []PomodoroSplit
+	for rows.Next() {
+		split, err := scanSplit(rows)
+		if err != nil {
+			return nil, err
+		}
+		splits = append(splits, split)
+	}
+
+	return splits, rows.Err()
+}
+
+// GetSplitOwner returns the pid of the process timing the split, or 0.
+func (s *SQLiteStore) GetSplitOwner(splitID int) (int, error) {
+	var owner int
+	err := s.db.QueryRow("SELECT owner_pid FROM pomodoro_splits WHERE id = ?", splitID).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return owner, err
+}
+
+// SetSplitOwner hands the split from one owner to another. It reports
+// false, changing nothing, when the split no longer belongs to from.
+func (s *SQLiteStore) SetSplitOwner(splitID, from, to int) (bool, error) {
+	result, err := s.db.Exec(
+		"UPDATE pomodoro_splits SET owner_pid = ? WHERE id = ? AND owner_pid = ?",
+		to, splitID, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	return changed == 1, err
+}
+
+func (s *SQLiteStore) UpdateSessionTotals(sessionID int) error {
 	now := time.Now()
-	_, err := db.Exec(`
+	_, err := s.db.Exec(`
 		UPDATE sessions
 		SET end_time = ?,
 		total_focus_seconds = (
@@ -213,8 +428,260 @@ func UpdateSessionTotals(db *sql.DB, sessionID int) error {
 	return err
 }
 
-func CloseSession(db *sql.DB, sessionID int) error {
+func (s *SQLiteStore) CloseSession(sessionID int) error {
 	now := time.Now()
-	_, err := db.Exec("UPDATE sessions SET end_time = ? WHERE id = ?", now, sessionID)
+	_, err := s.db.Exec("UPDATE sessions SET end_time = ? WHERE id = ?", now, sessionID)
+	return err
+}
+
+func (s *SQLiteStore) ListPresets() ([]Preset, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, focus_minutes, rest_minutes, long_break_minutes, cycle_length, builtin,
+			auto_continue, auto_continue_grace_seconds
+		FROM presets
+		ORDER BY builtin DESC, id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presets []Preset
+	for rows.Next() {
+		var preset Preset
+		err := rows.Scan(&preset.ID, &preset.Name, &preset.FocusMinutes, &preset.RestMinutes,
+			&preset.LongBreakMinutes, &preset.CycleLength, &preset.BuiltIn,
+			&preset.AutoContinue, &preset.AutoContinueGraceSeconds)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, preset)
+	}
+
+	return presets, rows.Err()
+}
+
+func (s *SQLiteStore) CreatePreset(preset Preset) (*Preset, error) {
+	// Checking the name in the same statement keeps this free of
+	// driver-specific error codes, so the package builds without cgo
+	result, err := s.db.Exec(`
+		INSERT INTO presets (name, focus_minutes, rest_minutes, long_break_minutes, cycle_length,
+			auto_continue, auto_continue_grace_seconds)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM presets WHERE name = ?)
+	`, preset.Name, preset.FocusMinutes, preset.RestMinutes, preset.LongBreakMinutes, preset.CycleLength,
+		preset.AutoContinue, preset.AutoContinueGraceSeconds, preset.Name)
+	if err != nil {
+		return nil, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if inserted == 0 {
+		return nil, ErrPresetExists
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	preset.ID = int(id)
+	preset.BuiltIn = false
+	return &preset, nil
+}
+
+func (s *SQLiteStore) SetPresetAutoContinue(presetID int, enabled bool, graceSeconds int) error {
+	_, err := s.db.Exec(
+		"UPDATE presets SET auto_continue = ?, auto_continue_grace_seconds = ? WHERE id = ?",
+		enabled, graceSeconds, presetID,
+	)
+	return err
+}
+
+// DeletePreset removes a user preset. Built-in presets are left alone.
+func (s *SQLiteStore) DeletePreset(presetID int) error {
+	_, err := s.db.Exec("DELETE FROM presets WHERE id = ? AND builtin = 0", presetID)
+	return err
+}
+
+func (s *SQLiteStore) CreateInterruption(interruption Interruption) (*Interruption, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO interruptions (split_id, phase, started_at, kind, reason)
+		VALUES (?, ?, ?, ?, ?)
+	`, interruption.SplitID, interruption.Phase, interruption.StartedAt, interruption.Kind, interruption.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	interruption.ID = int(id)
+	return &interruption, nil
+}
+
+func (s *SQLiteStore) UpdateInterruption(interruption *Interruption) error {
+	_, err := s.db.Exec(`
+		UPDATE interruptions
+		SET ended_at = ?, duration_seconds = ?, kind = ?, reason = ?
+		WHERE id = ?
+	`, interruption.EndedAt, interruption.DurationSeconds, interruption.Kind, interruption.Reason, interruption.ID)
+	return err
+}
+
+// GetSplitInterruptions returns the pauses of a split, oldest first.
+func (s *SQLiteStore) GetSplitInterruptions(splitID int) ([]Interruption, error) {
+	rows, err := s.db.Query(`
+		SELECT id, split_id, phase, started_at, ended_at, duration_seconds, kind, reason
+		FROM interruptions
+		WHERE split_id = ?
+		ORDER BY started_at ASC, id ASC
+	`, splitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interruptions []Interruption
+	for rows.Next() {
+		var interruption Interruption
+		var endedAt sql.NullTime
+		err := rows.Scan(&interruption.ID, &interruption.SplitID, &interruption.Phase,
+			&interruption.StartedAt, &endedAt, &interruption.DurationSeconds,
+			&interruption.Kind, &interruption.Reason)
+		if err != nil {
+			return nil, err
+		}
+		if endedAt.Valid {
+			interruption.EndedAt = &endedAt.Time
+		}
+		interruptions = append(interruptions, interruption)
+	}
+
+	return interruptions, rows.Err()
+}
+
+func (s *SQLiteStore) CreateSplitEvent(event SplitEvent) (*SplitEvent, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO split_events (split_id, kind, at, seconds)
+		VALUES (?, ?, ?, ?)
+	`, event.SplitID, event.Kind, event.At, event.Seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	event.ID = int(id)
+	return &event, nil
+}
+
+// GetSplitEvents returns the changes made to a split, oldest first.
+func (s *SQLiteStore) GetSplitEvents(splitID int) ([]SplitEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT id, split_id, kind, at, seconds
+		FROM split_events
+		WHERE split_id = ?
+		ORDER BY at ASC, id ASC
+	`, splitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []SplitEvent
+	for rows.Next() {
+		var event SplitEvent
+		if err := rows.Scan(&event.ID, &event.SplitID, &event.Kind, &event.At, &event.Seconds); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *SQLiteStore) QueueWebhook(delivery WebhookDelivery) (*WebhookDelivery, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO webhook_outbox (url, event, payload, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, delivery.URL, delivery.Event, delivery.Payload, delivery.CreatedAt.UTC(), delivery.NextAttemptAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.ID = int(id)
+	return &delivery, nil
+}
+
+// ClaimWebhooks returns deliveries due by now and pushes them back to
+// until, so another process draining the outbox leaves them alone.
+// Times are kept in UTC so they compare correctly as text.
+func (s *SQLiteStore) ClaimWebhooks(now, until time.Time, limit int) ([]WebhookDelivery, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, url, event, payload, created_at, attempts, next_attempt_at, last_error
+		FROM webhook_outbox
+		WHERE next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(&delivery.ID, &delivery.URL, &delivery.Event, &delivery.Payload,
+			&delivery.CreatedAt, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = until
+		if _, err := tx.Exec("UPDATE webhook_outbox SET next_attempt_at = ? WHERE id = ?", until.UTC(), deliveries[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, tx.Commit()
+}
+
+func (s *SQLiteStore) UpdateWebhook(delivery *WebhookDelivery) error {
+	_, err := s.db.Exec(`
+		UPDATE webhook_outbox
+		SET attempts = ?, next_attempt_at = ?, last_error = ?
+		WHERE id = ?
+	`, delivery.Attempts, delivery.NextAttemptAt.UTC(), delivery.LastError, delivery.ID)
+	return err
+}
+
+func (s *SQLiteStore) DeleteWebhook(deliveryID int) error {
+	_, err := s.db.Exec("DELETE FROM webhook_outbox WHERE id = ?", deliveryID)
 	return err
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// The TUI, the daemon and the command line each open the database, so
+// their writes must wait for one another instead of failing.
+func TestSQLiteStoresWriteConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	const writers, sessions = 4, 25
+
+	// Switching a new database to WAL does not wait for other connections
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store, err := NewSQLiteStore(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer store.Close()
+
+			for range sessions {
+				session, err := store.CreateSession("Concurrent")
+				if err == nil {
+					_, err = store.CreatePomodoroSplit(PomodoroSplit{SessionID: session.ID, FocusMinutes: 25, Mode: SplitModeCountdown})
+				}
+				if err == nil {
+					_, err = store.QueueWebhook(WebhookDelivery{URL: "http://example.invalid", Event: "focus_start", CreatedAt: wallNow(), NextAttemptAt: wallNow()})
+				}
+				if err == nil {
+					// Reads, then writes, in one transaction
+					_, err = store.ClaimWebhooks(wallNow(), wallNow(), 10)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	all, err := store.GetAllSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != writers*sessions {
+		t.Errorf("%d sessions, want %d", len(all), writers*sessions)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+
+	ExportSessions = "sessions"
+	ExportSplits   = "splits"
+)
+
+// The CSV columns match the JSON field names and never change order;
+// new columns are only ever added at the end.
+var exportSessionColumns = []string{
+	"id", "name", "start_time", "end_time", "total_focus_seconds", "total_rest_seconds",
+	"auto_continue", "auto_continue_grace_seconds", "soft_end", "interruption_count",
+}
+
+var exportSplitColumns = []string{
+	"id", "session_id", "focus_minutes", "rest_minutes", "start_time", "end_time", "status",
+	"actual_focus_seconds", "actual_rest_seconds", "cycle_position", "cycle_length", "mode",
+	"focus_extension_seconds", "rest_extension_seconds", "focus_skipped", "rest_skipped", "restarts",
+	"focus_overtime_seconds", "rest_overtime_seconds",
+}
+
+func sessionRecord(s Session) []string {
+	return []string{
+		strconv.Itoa(s.ID), s.Name, formatTimestamp(&s.StartTime), formatTimestamp(s.EndTime),
+		strconv.Itoa(s.TotalFocusSeconds), strconv.Itoa(s.TotalRestSeconds),
+		strconv.FormatBool(s.AutoContinue), strconv.Itoa(s.AutoContinueGraceSeconds),
+		strconv.FormatBool(s.SoftEnd), strconv.Itoa(s.InterruptionCount),
+	}
+}
+
+func splitRecord(s PomodoroSplit) []string {
+	return []string{
+		strconv.Itoa(s.ID), strconv.Itoa(s.SessionID), strconv.Itoa(s.FocusMinutes), strconv.Itoa(s.RestMinutes),
+		formatTimestamp(&s.StartTime), formatTimestamp(s.EndTime), s.Status,
+		strconv.Itoa(s.ActualFocusSeconds), strconv.Itoa(s.ActualRestSeconds),
+		strconv.Itoa(s.CyclePosition), strconv.Itoa(s.CycleLength), s.Mode,
+		strconv.Itoa(s.FocusExtensionSeconds), strconv.Itoa(s.RestExtensionSeconds),
+		strconv.FormatBool(s.FocusSkipped), strconv.FormatBool(s.RestSkipped), strconv.Itoa(s.Restarts),
+		strconv.Itoa(s.FocusOvertimeSeconds), strconv.Itoa(s.RestOvertimeSeconds),
+	}
+}
+
+// formatTimestamp is RFC 3339 with the zone offset; empty for no time.
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func writeCSV[T any](w io.Writer, columns []string, records []T, record func(T) []string) error {
+	writer := csv.NewWriter(w)
+	writer.Write(columns)
+	for _, r := range records {
+		writer.Write(record(r))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// sessionIn and splitIn move every timestamp into loc, so the whole
+// export uses one offset.
+func sessionIn(s Session, loc *time.Location) Session {
+	s.StartTime = s.StartTime.In(loc)
+	if s.EndTime != nil {
+		end := s.EndTime.In(loc)
+		s.EndTime = &end
+	}
+	return s
+}
+
+func splitIn(s PomodoroSplit, loc *time.Location) PomodoroSplit {
+	s.StartTime = s.StartTime.In(loc)
+	if s.EndTime != nil {
+		end := s.EndTime.In(loc)
+		s.EndTime = &end
+	}
+	return s
+}
+
+// exportData loads the sessions and the splits that started in
+// [from, to), oldest first. Either bound may be nil.
+func exportData(store Store, from, to *time.Time, loc *time.Location) ([]Session, []PomodoroSplit, error) {
+	inRange := func(t time.Time) bool {
+		return (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+	}
+
+	all, err := store.GetAllSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.SortStableFunc(all, func(a, b Session) int {
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+
+	bySession, err := splitsBySession(store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sessions []Session
+	var splits []PomodoroSplit
+	for _, session := range all {
+		if inRange(session.StartTime) {
+			sessions = append(sessions, sessionIn(session, loc))
+		}
+		for _, split := range bySession[session.ID] {
+			if inRange(split.StartTime) {
+				splits = append(splits, splitIn(split, loc))
+			}
+		}
+	}
+	return sessions, splits, nil
+}
+
+func writeExport(w io.Writer, format, level string, sessions []Session, splits []PomodoroSplit) error {
+	switch {
+	case format == ExportJSON && level == ExportSplits:
+		return writeList(w, OutputJSON, "splits", "split", splits)
+	case format == ExportJSON:
+		return writeList(w, OutputJSON, "sessions", "session", sessions)
+	case level == ExportSplits:
+		return writeCSV(w, exportSplitColumns, splits, splitRecord)
+	}
+	return writeCSV(w, exportSessionColumns, sessions, sessionRecord)
+}
+
+// parseExportTime reads a date or an RFC 3339 time. A bare date given as
+// the end of the range includes that whole day.
+func parseExportTime(value string, end bool, loc *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a date (2006-01-02) nor an RFC 3339 time", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// exportSession writes the splits of one session to a CSV file in
+// exportDir, for the session browser.
+func exportSession(store Store, session Session) (string, error) {
+	splits, err := store.GetSessionSplits(session.ID)
+	if err != nil {
+		return "", err
+	}
+	for i := range splits {
+		splits[i] = splitIn(splits[i], time.Local)
+	}
+	dir, err := exportDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("romodoro-session-%d.csv", session.ID))
+	return path, writeExportFile(path, ExportCSV, ExportSplits, nil, splits)
+}
+
+func cmdExport(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", ExportCSV, "output `format`: csv or json")
+	level := flags.String("level", ExportSessions, "one row per session or per split: `sessions` or splits")
+	fromFlag := flags.String("from", "", "only what started on or after this `date` or RFC 3339 time")
+	toFlag := flags.String("to", "", "only what started before this time, or on or before this `date`")
+	utc := flags.Bool("utc", false, "write timestamps in UTC instead of local time")
+	file := flags.String("file", "", "write to `path` instead of standard output")
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	loc := time.Local
+	if *utc {
+		loc = time.UTC
+	}
+	from, err := parseExportTime(*fromFlag, false, loc)
+	if err != nil {
+		err = fmt.Errorf("--from: %w", err)
+	}
+	to, toErr := parseExportTime(*toFlag, true, loc)
+	switch {
+	case err != nil:
+	case toErr != nil:
+		err = fmt.Errorf("--to: %w", toErr)
+	case *format != ExportCSV && *format != ExportJSON:
+		err = fmt.Errorf("unknown format %q", *format)
+	case *level != ExportSessions && *level != ExportSplits:
+		err = fmt.Errorf("unknown level %q", *level)
+	case flags.NArg() > 0:
+		err = fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro export:", err)
+		return ExitUsage
+	}
+
+	sessions, splits, err := exportData(store, from, to, loc)
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro export:", err)
+		return ExitError
+	}
+
+	if *file == "" {
+		err = writeExport(stdout, *format, *level, sessions, splits)
+	} else {
+		err = writeExportFile(*file, *format, *level, sessions, splits)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro export:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// writeExportFile writes through a temporary file so a failed export
+// never leaves half a file behind.
+func writeExportFile(path, format, level string, sessions []Session, splits []PomodoroSplit) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".romodoro-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeExport(tmp, format, level, sessions, splits); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Each CSV column must carry the same value as the JSON field of that name.
+func TestExportColumnsMatchJSON(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	session := Session{
+		ID: 1, Name: "Writing, \"draft\"", StartTime: start, EndTime: &end,
+		TotalFocusSeconds: 1500, TotalRestSeconds: 300,
+		AutoContinue: true, AutoContinueGraceSeconds: 10, SoftEnd: true, InterruptionCount: 2,
+	}
+	split := PomodoroSplit{
+		ID: 3, SessionID: 1, FocusMinutes: 25, RestMinutes: 5, StartTime: start, EndTime: &end,
+		Status: "completed", ActualFocusSeconds: 1500, ActualRestSeconds: 300,
+		CyclePosition: 2, CycleLength: 4, Mode: SplitModeCountdown,
+		FocusExtensionSeconds: 300, RestExtensionSeconds: 60, FocusSkipped: true, RestSkipped: true,
+		Restarts: 1, FocusOvertimeSeconds: 90, RestOvertimeSeconds: 30,
+	}
+
+	tests := []struct {
+		level  string
+		record any
+	}{
+		{ExportSessions, session},
+		{ExportSplits, split},
+	}
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeExport(&out, ExportCSV, test.level, []Session{session}, []PomodoroSplit{split}); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(&out).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 {
+				t.Fatalf("%d rows, want a header and one record", len(rows))
+			}
+
+			raw, err := json.Marshal(test.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			header, record := rows[0], rows[1]
+			if len(header) != len(record) {
+				t.Fatalf("%d columns, %d values", len(header), len(record))
+			}
+			for i, column := range header {
+				value, ok := fields[column]
+				if !ok {
+					t.Errorf("column %q is not a JSON field", column)
+					continue
+				}
+				if want := fmt.Sprint(value); record[i] != want {
+					t.Errorf("%s = %q, want %q", column, record[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExportTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	tests := []struct {
+		value   string
+		end     bool
+		want    string // RFC 3339, empty for no bound
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "2026-03-10", want: "2026-03-10T00:00:00+02:00"},
+		{value: "2026-03-10", end: true, want: "2026-03-11T00:00:00+02:00"},
+		{value: "2026-03-31", end: true, want: "2026-04-01T00:00:00+02:00"},
+		{value: "2026-03-10T12:30:00Z", want: "2026-03-10T12:30:00Z"},
+		{value: "2026-03-10T12:30:00Z", end: true, want: "2026-03-10T12:30:00Z"},
+		{value: "10/03/2026", wantErr: true},
+		{value: "2026-02-30", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseExportTime(test.value, test.end, loc)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseExportTime(%q) = %v, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseExportTime(%q): %v", test.value, err)
+			continue
+		}
+		switch {
+		case test.want == "" && got != nil:
+			t.Errorf("parseExportTime(%q) = %v, want no bound", test.value, got)
+		case test.want != "" && (got == nil || got.Format(time.RFC3339) != test.want):
+			t.Errorf("parseExportTime(%q, end %v) = %v, want %s", test.value, test.end, got, test.want)
+		}
+	}
+}
+
+// --from and --to are whole days in the export's time zone, and --to
+// includes its day.
+func TestExportDayBoundaries(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	store := NewMemoryStore()
+	for _, start := range []string{
+		"2026-03-09T23:59:59+02:00",
+		"2026-03-10T00:00:00+02:00",
+		"2026-03-11T23:59:59+02:00",
+		"2026-03-12T00:00:00+02:00",
+	} {
+		at, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := store.CreateSession(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		split, err := store.CreatePomodoroSplit(PomodoroSplit{SessionID: session.ID, FocusMinutes: 25})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The stores stamp new rows with the current time
+		store.data.Sessions[store.sessionIndex(session.ID)].StartTime = at.UTC()
+		store.data.Splits[store.splitIndex(split.ID)].StartTime = at.UTC()
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		loc      *time.Location
+		want     []string // session names, which are their start times
+	}{
+		{name: "both days", from: "2026-03-10", to: "2026-03-11", loc: loc,
+			want: []string{"2026-03-10T00:00:00+02:00", "2026-03-11T23:59:59+02:00"}},
+		{name: "one day", from: "2026-03-10", to: "2026-03-10", loc: loc,
+			want: []string{"2026-03-10T00:00:00+02:00"}},
+		{name: "from only", from: "2026-03-11", loc: loc,
+			want: []string{"2026-03-11T23:59:59+02:00", "2026-03-12T00:00:00+02:00"}},
+		{name: "to only", to: "2026-03-10", loc: loc,
+			want: []string{"2026-03-09T23:59:59+02:00", "2026-03-10T00:00:00+02:00"}},
+		{name: "exact end is exclusive", from: "2026-03-10", to: "2026-03-11T23:59:59+02:00", loc: loc,
+			want: []string{"2026-03-10T00:00:00+02:00"}},
+		{name: "days in UTC", from: "2026-03-10", to: "2026-03-11", loc: time.UTC,
+			want: []string{"2026-03-11T23:59:59+02:00", "2026-03-12T00:00:00+02:00"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, err := parseExportTime(test.from, false, test.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := parseExportTime(test.to, true, test.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions, splits, err := exportData(store, from, to, test.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, session := range sessions {
+				names = append(names, session.Name)
+				if session.StartTime.Location() != test.loc {
+					t.Errorf("session %q exported in %v, want %v", session.Name, session.StartTime.Location(), test.loc)
+				}
+			}
+			// Each session has one split, started with it
+			if len(splits) != len(sessions) {
+				t.Errorf("%d splits for %d sessions", len(splits), len(sessions))
+			}
+			for i := range min(len(splits), len(sessions)) {
+				if splits[i].SessionID != sessions[i].ID || !splits[i].StartTime.Equal(sessions[i].StartTime) {
+					t.Errorf("split %d started %v, want the start of session %q", splits[i].ID, splits[i].StartTime, sessions[i].Name)
+				}
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("sessions %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"romodoro/timer"
+)
+
+const (
+	HookSessionCreated = "session_created"
+	HookFocusStart     = "focus_start"
+	HookFocusEnd       = "focus_end"
+	HookRestStart      = "rest_start"
+	HookRestEnd        = "rest_end"
+	HookPaused         = "paused"
+	HookResumed        = "resumed"
+	HookSplitCancelled = "split_cancelled"
+)
+
+// hookEvents lists every event, for validating the config.
+var hookEvents = []string{
+	HookSessionCreated, HookFocusStart, HookFocusEnd, HookRestStart,
+	HookRestEnd, HookPaused, HookResumed, HookSplitCancelled,
+}
+
+// EventPayload is what hooks read on stdin and webhooks receive, inside
+// the same envelope as the JSON output of the CLI.
+type EventPayload struct {
+	Event   string         `json:"event"`
+	At      time.Time      `json:"at"`
+	Session *Session       `json:"session"`
+	Split   *PomodoroSplit `json:"split"`
+}
+
+// pendingHooks lets the process wait for hooks before it exits.
+var pendingHooks sync.WaitGroup
+
+// hookForEvent names the hook a timer event triggers, or "" for none.
+// Cancelling is left to whoever records the cancelled split.
+func hookForEvent(event timer.Event) string {
+	switch event.Type {
+	case timer.PhaseStarted:
+		if event.Phase == timer.Rest {
+			return HookRestStart
+		}
+		return HookFocusStart
+	case timer.PhaseEnded:
+		if event.Phase == timer.Rest {
+			return HookRestEnd
+		}
+		return HookFocusEnd
+	case timer.Paused:
+		return HookPaused
+	case timer.Resumed:
+		return HookResumed
+	}
+	return ""
+}
+
+// emitEvent runs the hooks and queues the webhooks for event. The payload
+// is taken before returning, so callers may go on changing the session
+// and split.
+func emitEvent(store Store, event string, session *Session, split *PomodoroSplit) {
+	if event == "" {
+		return
+	}
+	payload := EventPayload{Event: event, At: time.Now(), Session: session, Split: split}
+	body, err := json.Marshal(envelope{SchemaVersion: outputSchemaVersion, Type: "event", Data: payload})
+	if err != nil {
+		logHookFailure(event, "", err)
+		return
+	}
+
+	runHooks(event, append(body, '\n'), hookEnv(event, session, split))
+	queueWebhooks(store, event, body, payload.At)
+}
+
+// runHooks starts the commands configured for event in the background.
+func runHooks(event string, stdin []byte, env []string) {
+	config := currentConfig().Hooks
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	for _, command := range config.commands(event) {
+		pendingHooks.Add(1)
+		go func() {
+			defer pendingHooks.Done()
+			if err := runHook(command, stdin, env, timeout); err != nil {
+				logHookFailure(event, command, err)
+			}
+		}()
+	}
+}
+
+// waitHooks blocks until running hooks finish or time out.
+func waitHooks() {
+	pendingHooks.Wait()
+}
+
+func hookEnv(event string, session *Session, split *PomodoroSplit) []string {
+	env := []string{"ROMODORO_HOOK=" + event}
+	if session != nil {
+		env = append(env,
+			"ROMODORO_SESSION_ID="+strconv.Itoa(session.ID),
+			"ROMODORO_SESSION_NAME="+session.Name,
+		)
+	}
+	if split != nil {
+		env = append(env,
+			"ROMODORO_SPLIT_ID="+strconv.Itoa(split.ID),
+			"ROMODORO_SPLIT_STATUS="+split.Status,
+			"ROMODORO_SPLIT_MODE="+split.Mode,
+			"ROMODORO_FOCUS_MINUTES="+strconv.Itoa(split.FocusMinutes),
+			"ROMODORO_REST_MINUTES="+strconv.Itoa(split.RestMinutes),
+			"ROMODORO_FOCUS_SECONDS="+strconv.Itoa(split.ActualFocusSeconds),
+			"ROMODORO_REST_SECONDS="+strconv.Itoa(split.ActualRestSeconds),
+		)
+	}
+	return env
+}
+
+func runHook(command string, stdin []byte, env []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait on pipes held open by something the hook left running
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil && output.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return err
+}
+
+// shellCommand runs command through the platform's shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+var hookLogMu sync.Mutex
+
+// logHookFailure appends to hooks.log in the data directory; the TUI owns
+// the terminal, so there is nowhere else to report to. Target is the hook
+// command or webhook URL.
+func logHookFailure(event, target string, failure error) {
+	hookLogMu.Lock()
+	defer hookLogMu.Unlock()
+
+	dir, err := dataDir()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "hooks.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "%s %s %q: %v\n", time.Now().Format(time.RFC3339), event, target, failure)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// beginInterruption logs the start of a pause. The kind and reason can be
+// filled in while paused.
+func (m *App) beginInterruption() {
+	if m.currentSplit == nil {
+		return
+	}
+	interruption, err := m.store.CreateInterruption(Interruption{
+		SplitID:   m.currentSplit.ID,
+		Phase:     m.timer.Phase().String(),
+		StartedAt: wallNow(),
+	})
+	if err != nil {
+		return
+	}
+	m.interruption = interruption
+	m.refreshSessionData()
+}
+
+func (m *App) endInterruption() {
+	if m.interruption == nil {
+		return
+	}
+	now := time.Now()
+	m.interruption.EndedAt = &now
+	m.interruption.DurationSeconds = int(now.Sub(m.interruption.StartedAt).Seconds())
+	m.store.UpdateInterruption(m.interruption)
+	m.interruption = nil
+}
+
+func (m *App) setInterruptionKind(kind string) {
+	if m.interruption == nil {
+		return
+	}
+	m.interruption.Kind = kind
+	m.store.UpdateInterruption(m.interruption)
+}
+
+func (m *App) beginInterruptionReason() (tea.Model, tea.Cmd) {
+	if m.interruption == nil {
+		return m, nil
+	}
+	m.enteringReason = true
+	m.textInput.CharLimit = 80
+	m.textInput.Placeholder = "Why did you stop?"
+	m.textInput.SetValue(m.interruption.Reason)
+	return m, textinput.Blink
+}
+
+func (m *App) updateInterruptionReason(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		m.interruption.Reason = strings.TrimSpace(m.textInput.Value())
+		m.store.UpdateInterruption(m.interruption)
+		m.enteringReason = false
+		return m, nil
+	case "esc":
+		m.enteringReason = false
+		return m, nil
+	}
+
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
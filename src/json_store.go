@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// JSONStore is a MemoryStore that rewrites a plain JSON file after every
+// change. It needs no cgo, which makes it the fallback for builds without
+// go-sqlite3.
+//
+// The TUI, the daemon, `serve` and one-off commands may all have the file
+// open at once. Every operation holds an exclusive lock on a sidecar
+// `.lock` file and rereads the JSON file first if another process has
+// replaced it, so each change is applied to the latest data.
+type JSONStore struct {
+	*MemoryStore
+	path     string
+	lockFile *os.File
+
+	// loaded is the file as this process last read or wrote it; stale is
+	// set when a write failed and memory no longer matches the file.
+	loaded os.FileInfo
+	stale  bool
+}
+
+func NewJSONStore(path string) (*JSONStore, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &JSONStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+		lockFile:    lock,
+	}
+	store.persist = store.save
+	store.acquire = store.reload
+	store.release = store.releaseLock
+
+	// Load the file now so a corrupt one is reported on startup
+	if err := store.reload(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	store.releaseLock()
+	return store, nil
+}
+
+// reload takes the file lock and rereads the file if it changed since
+// this process last saw it.
+func (s *JSONStore) reload() error {
+	if err := lockFile(s.lockFile); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		info, err = nil, nil
+	}
+	if err != nil {
+		s.releaseLock()
+		return err
+	}
+	if !s.stale && sameFileVersion(s.loaded, info) {
+		return nil
+	}
+
+	// Start from the seeded presets, as for a new store
+	data := NewMemoryStore().data
+	if info != nil {
+		encoded, err := os.ReadFile(s.path)
+		if err != nil {
+			s.releaseLock()
+			return err
+		}
+		if len(encoded) > 0 {
+			if err := json.Unmarshal(encoded, &data); err != nil {
+				s.releaseLock()
+				return err
+			}
+		}
+	}
+
+	s.data = data
+	s.loaded = info
+	s.stale = false
+	return nil
+}
+
+func (s *JSONStore) releaseLock() {
+	unlockFile(s.lockFile)
+}
+
+// sameFileVersion reports whether a and b describe the same write of the
+// file. Every save replaces the file, so a new write is a new file even
+// when the clock is too coarse to tell the modification times apart.
+func sameFileVersion(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// save writes to a temporary file first so a crash mid-write never leaves
+// a truncated database behind.
+func (s *JSONStore) save(data memoryData) (err error) {
+	defer func() {
+		if err != nil {
+			s.stale = true
+		}
+	}()
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".sessions-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.loaded, err = os.Stat(s.path)
+	return err
+}
+
+func (s *JSONStore) Close() error {
+	return s.lockFile.Close()
+}
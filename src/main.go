@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -10,30 +11,61 @@ import (
 )
 
 func main() {
-	homeDir, err := os.UserHomeDir()
+	flags := flag.NewFlagSet("romodoro", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	dbFlag := flags.String("db", "", "database `path`")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(ExitOK)
+		}
+		os.Exit(ExitUsage)
+	}
+
+	// A broken config only stops commands other than `config` itself
+	if path, err := configPath(); err == nil {
+		config, err := loadConfig(path)
+		if err != nil && flags.Arg(0) != "config" {
+			fmt.Fprintln(os.Stderr, "romodoro: invalid config:", err)
+			os.Exit(ExitBadConfig)
+		}
+		if err == nil {
+			setConfig(config)
+		}
+	}
+
+	backend := os.Getenv("ROMODORO_STORE")
+	dbPath, err := resolveStorePath(*dbFlag, backend, os.Stderr)
 	if err != nil {
-		log.Fatal("Could not get home directory:", err)
+		log.Fatal("Could not locate database:", err)
 	}
 
-	dbPath := filepath.Join(homeDir, "romodoro", "data", "sessions.db")
-	
+	setSocketStore(backend, dbPath)
+
 	// Ensure data directory exists
-	dataDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		log.Fatal("Could not create data directory:", err)
 	}
 
-	db, err := InitDB(dbPath)
+	store, err := OpenStore(backend, dbPath)
 	if err != nil {
 		log.Fatal("Could not initialize database:", err)
 	}
-	defer db.Close()
+	defer store.Close()
+
+	if flags.NArg() > 0 {
+		code := runCommand(store, flags.Args(), os.Stdout, os.Stderr)
+		flushWebhooks(store)
+		store.Close()
+		waitHooks()
+		os.Exit(code)
+	}
+
+	app := NewApp(store)
 
-	app := NewApp(db)
-	
 	p := tea.NewProgram(app, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running program: %v", err)
 		os.Exit(1)
 	}
+	waitHooks()
 }
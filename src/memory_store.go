@@ -0,0 +1,679 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryData struct {
+	NextSessionID int             `json:"next_session_id"`
+	NextSplitID   int             `json:"next_split_id"`
+	NextPresetID  int             `json:"next_preset_id"`
+	Sessions      []Session       `json:"sessions"`
+	Splits        []PomodoroSplit `json:"splits"`
+	Presets       []Preset        `json:"presets"`
+
+	NextInterruptionID int            `json:"next_interruption_id"`
+	Interruptions      []Interruption `json:"interruptions"`
+
+	NextSplitEventID int          `json:"next_split_event_id"`
+	SplitEvents      []SplitEvent `json:"split_events"`
+
+	NextWebhookID int               `json:"next_webhook_id"`
+	Webhooks      []WebhookDelivery `json:"webhook_outbox"`
+
+	// SplitOwners maps split IDs to the pid of the process timing them.
+	SplitOwners map[int]int `json:"split_owners,omitempty"`
+}
+
+func (d *memoryData) seedPresets() {
+	d.NextPresetID = 1
+	for _, preset := range builtinPresets {
+		preset.ID = d.NextPresetID
+		d.NextPresetID++
+		d.Presets = append(d.Presets, preset)
+	}
+}
+
+// MemoryStore keeps everything in process memory. It is used directly for
+// ephemeral runs and as the engine behind JSONStore.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data memoryData
+
+	// persist, when set, is called with the lock held after every change.
+	persist func(memoryData) error
+
+	// acquire and release, when set, run around every operation, so
+	// JSONStore can hold its file lock and reload the file first.
+	acquire func() error
+	release func()
+}
+
+// NewMemoryStore returns an empty store holding only the built-in presets.
+// JSONStore loads its file over this, so older files without presets keep
+// the seeded ones.
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{
+		data: memoryData{NextSessionID: 1, NextSplitID: 1},
+	}
+	store.data.seedPresets()
+	return store
+}
+
+func (s *MemoryStore) lock() error {
+	s.mu.Lock()
+	if s.acquire == nil {
+		return nil
+	}
+	if err := s.acquire(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryStore) unlock() {
+	if s.release != nil {
+		s.release()
+	}
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) commit() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist(s.data)
+}
+
+func (s *MemoryStore) sessionIndex(sessionID int) int {
+	for i := range s.data.Sessions {
+		if s.data.Sessions[i].ID == sessionID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) splitIndex(splitID int) int {
+	for i := range s.data.Splits {
+		if s.data.Splits[i].ID == splitID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) interruptionCount(sessionID int) int {
+	count := 0
+	for _, interruption := range s.data.Interruptions {
+		if i := s.splitIndex(interruption.SplitID); i >= 0 && s.data.Splits[i].SessionID == sessionID {
+			count++
+		}
+	}
+	return count
+}
+
+// copySession returns a copy safe to hand out, with the derived fields
+// filled in. The lock must be held.
+func (s *MemoryStore) copySession(session Session) *Session {
+	if session.EndTime != nil {
+		endTime := *session.EndTime
+		session.EndTime = &endTime
+	}
+	session.InterruptionCount = s.interruptionCount(session.ID)
+	return &session
+}
+
+func (s *MemoryStore) CreateSession(name string) (*Session, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	now := wallNow()
+	endTime := now
+	session := Session{
+		ID:        s.data.NextSessionID,
+		Name:      name,
+		StartTime: now,
+		EndTime:   &endTime,
+	}
+	s.data.NextSessionID++
+	s.data.Sessions = append(s.data.Sessions, session)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return s.copySession(session), nil
+}
+
+func (s *MemoryStore) GetSession(sessionID int) (*Session, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	i := s.sessionIndex(sessionID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return s.copySession(s.data.Sessions[i]), nil
+}
+
+func (s *MemoryStore) GetLastSession() (*Session, error) {
+	sessions, err := s.GetAllSessions()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return &sessions[0], nil
+}
+
+func (s *MemoryStore) GetAllSessions() ([]Session, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var sessions []Session
+	for _, session := range s.data.Sessions {
+		sessions = append(sessions, *s.copySession(session))
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.After(sessions[j].StartTime)
+	})
+	return sessions, nil
+}
+
+func (s *MemoryStore) DeleteSession(sessionID int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	interruptions := s.data.Interruptions[:0]
+	for _, interruption := range s.data.Interruptions {
+		if i := s.splitIndex(interruption.SplitID); i < 0 || s.data.Splits[i].SessionID != sessionID {
+			interruptions = append(interruptions, interruption)
+		}
+	}
+	s.data.Interruptions = interruptions
+
+	events := s.data.SplitEvents[:0]
+	for _, event := range s.data.SplitEvents {
+		if i := s.splitIndex(event.SplitID); i < 0 || s.data.Splits[i].SessionID != sessionID {
+			events = append(events, event)
+		}
+	}
+	s.data.SplitEvents = events
+
+	splits := s.data.Splits[:0]
+	for _, split := range s.data.Splits {
+		if split.SessionID != sessionID {
+			splits = append(splits, split)
+		} else {
+			delete(s.data.SplitOwners, split.ID)
+		}
+	}
+	s.data.Splits = splits
+
+	if i := s.sessionIndex(sessionID); i >= 0 {
+		s.data.Sessions = append(s.data.Sessions[:i], s.data.Sessions[i+1:]...)
+	}
+
+	return s.commit()
+}
+
+func (s *MemoryStore) CloseSession(sessionID int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	if i := s.sessionIndex(sessionID); i >= 0 {
+		now := time.Now()
+		s.data.Sessions[i].EndTime = &now
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) UpdateSessionTotals(sessionID int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	i := s.sessionIndex(sessionID)
+	if i < 0 {
+		return nil
+	}
+
+	focus, rest := 0, 0
+	for _, split := range s.data.Splits {
+		if split.SessionID == sessionID {
+			focus += split.ActualFocusSeconds
+			rest += split.ActualRestSeconds
+		}
+	}
+
+	now := time.Now()
+	s.data.Sessions[i].EndTime = &now
+	s.data.Sessions[i].TotalFocusSeconds = focus
+	s.data.Sessions[i].TotalRestSeconds = rest
+
+	return s.commit()
+}
+
+func (s *MemoryStore) SetSessionSoftEnd(sessionID int, enabled bool) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	if i := s.sessionIndex(sessionID); i >= 0 {
+		s.data.Sessions[i].SoftEnd = enabled
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) SetSessionAutoContinue(sessionID int, enabled bool, graceSeconds int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	if i := s.sessionIndex(sessionID); i >= 0 {
+		s.data.Sessions[i].AutoContinue = enabled
+		s.data.Sessions[i].AutoContinueGraceSeconds = graceSeconds
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) CreatePomodoroSplit(split PomodoroSplit) (*PomodoroSplit, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	split.ID = s.data.NextSplitID
+	split.StartTime = wallNow()
+	split.EndTime = nil
+	split.Status = "in_progress"
+	if split.Mode == "" {
+		split.Mode = SplitModeCountdown
+	}
+	s.data.NextSplitID++
+	s.data.Splits = append(s.data.Splits, split)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
+
+func (s *MemoryStore) UpdatePomodoroSplit(split *PomodoroSplit) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	i := s.splitIndex(split.ID)
+	if i < 0 {
+		return nil
+	}
+
+	stored := &s.data.Splits[i]
+	if split.EndTime != nil {
+		endTime := *split.EndTime
+		stored.EndTime = &endTime
+	} else {
+		stored.EndTime = nil
+	}
+	stored.Status = split.Status
+	stored.RestMinutes = split.RestMinutes
+	stored.ActualFocusSeconds = split.ActualFocusSeconds
+	stored.ActualRestSeconds = split.ActualRestSeconds
+	stored.FocusExtensionSeconds = split.FocusExtensionSeconds
+	stored.RestExtensionSeconds = split.RestExtensionSeconds
+	stored.FocusSkipped = split.FocusSkipped
+	stored.RestSkipped = split.RestSkipped
+	stored.Restarts = split.Restarts
+	stored.FocusOvertimeSeconds = split.FocusOvertimeSeconds
+	stored.RestOvertimeSeconds = split.RestOvertimeSeconds
+
+	return s.commit()
+}
+
+func (s *MemoryStore) GetSessionSplits(sessionID int) ([]PomodoroSplit, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var splits []PomodoroSplit
+	for _, split := range s.data.Splits {
+		if split.SessionID == sessionID {
+			splits = append(splits, split)
+		}
+	}
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].StartTime.Before(splits[j].StartTime)
+	})
+	return splits, nil
+}
+
+func (s *MemoryStore) GetInProgressSplits() ([]PomodoroSplit, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var splits []PomodoroSplit
+	for _, split := range s.data.Splits {
+		if split.Status == "in_progress" {
+			splits = append(splits, split)
+		}
+	}
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].StartTime.Before(splits[j].StartTime)
+	})
+	return splits, nil
+}
+
+func (s *MemoryStore) GetAllSplits() ([]PomodoroSplit, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	splits := slices.Clone(s.data.Splits)
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].StartTime.Before(splits[j].StartTime)
+	})
+	return splits, nil
+}
+
+func (s *MemoryStore) GetSplitOwner(splitID int) (int, error) {
+	if err := s.lock(); err != nil {
+		return 0, err
+	}
+	defer s.unlock()
+
+	if s.splitIndex(splitID) < 0 {
+		return 0, ErrNotFound
+	}
+	return s.data.SplitOwners[splitID], nil
+}
+
+func (s *MemoryStore) SetSplitOwner(splitID, from, to int) (bool, error) {
+	if err := s.lock(); err != nil {
+		return false, err
+	}
+	defer s.unlock()
+
+	if s.splitIndex(splitID) < 0 || s.data.SplitOwners[splitID] != from {
+		return false, nil
+	}
+	if s.data.SplitOwners == nil {
+		s.data.SplitOwners = make(map[int]int)
+	}
+	if to == 0 {
+		delete(s.data.SplitOwners, splitID)
+	} else {
+		s.data.SplitOwners[splitID] = to
+	}
+	return true, s.commit()
+}
+
+func (s *MemoryStore) CreateInterruption(interruption Interruption) (*Interruption, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	if s.data.NextInterruptionID == 0 {
+		s.data.NextInterruptionID = 1
+	}
+	interruption.ID = s.data.NextInterruptionID
+	s.data.NextInterruptionID++
+	s.data.Interruptions = append(s.data.Interruptions, interruption)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return &interruption, nil
+}
+
+func (s *MemoryStore) UpdateInterruption(interruption *Interruption) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	for i := range s.data.Interruptions {
+		if s.data.Interruptions[i].ID == interruption.ID {
+			stored := &s.data.Interruptions[i]
+			if interruption.EndedAt != nil {
+				endedAt := *interruption.EndedAt
+				stored.EndedAt = &endedAt
+			}
+			stored.DurationSeconds = interruption.DurationSeconds
+			stored.Kind = interruption.Kind
+			stored.Reason = interruption.Reason
+		}
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) GetSplitInterruptions(splitID int) ([]Interruption, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var interruptions []Interruption
+	for _, interruption := range s.data.Interruptions {
+		if interruption.SplitID == splitID {
+			if interruption.EndedAt != nil {
+				endedAt := *interruption.EndedAt
+				interruption.EndedAt = &endedAt
+			}
+			interruptions = append(interruptions, interruption)
+		}
+	}
+	sort.SliceStable(interruptions, func(i, j int) bool {
+		return interruptions[i].StartedAt.Before(interruptions[j].StartedAt)
+	})
+	return interruptions, nil
+}
+
+func (s *MemoryStore) CreateSplitEvent(event SplitEvent) (*SplitEvent, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	if s.data.NextSplitEventID == 0 {
+		s.data.NextSplitEventID = 1
+	}
+	event.ID = s.data.NextSplitEventID
+	s.data.NextSplitEventID++
+	s.data.SplitEvents = append(s.data.SplitEvents, event)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *MemoryStore) GetSplitEvents(splitID int) ([]SplitEvent, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var events []SplitEvent
+	for _, event := range s.data.SplitEvents {
+		if event.SplitID == splitID {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+	return events, nil
+}
+
+func (s *MemoryStore) ListPresets() ([]Preset, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var presets []Preset
+	for _, preset := range s.data.Presets {
+		if preset.BuiltIn {
+			presets = append(presets, preset)
+		}
+	}
+	for _, preset := range s.data.Presets {
+		if !preset.BuiltIn {
+			presets = append(presets, preset)
+		}
+	}
+	return presets, nil
+}
+
+func (s *MemoryStore) CreatePreset(preset Preset) (*Preset, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	for _, existing := range s.data.Presets {
+		if existing.Name == preset.Name {
+			return nil, ErrPresetExists
+		}
+	}
+
+	preset.ID = s.data.NextPresetID
+	preset.BuiltIn = false
+	s.data.NextPresetID++
+	s.data.Presets = append(s.data.Presets, preset)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
+
+func (s *MemoryStore) SetPresetAutoContinue(presetID int, enabled bool, graceSeconds int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	for i := range s.data.Presets {
+		if s.data.Presets[i].ID == presetID {
+			s.data.Presets[i].AutoContinue = enabled
+			s.data.Presets[i].AutoContinueGraceSeconds = graceSeconds
+		}
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) DeletePreset(presetID int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	for i, preset := range s.data.Presets {
+		if preset.ID == presetID && !preset.BuiltIn {
+			s.data.Presets = append(s.data.Presets[:i], s.data.Presets[i+1:]...)
+			break
+		}
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) QueueWebhook(delivery WebhookDelivery) (*WebhookDelivery, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	if s.data.NextWebhookID == 0 {
+		s.data.NextWebhookID = 1
+	}
+	delivery.ID = s.data.NextWebhookID
+	s.data.NextWebhookID++
+	s.data.Webhooks = append(s.data.Webhooks, delivery)
+
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *MemoryStore) ClaimWebhooks(now, until time.Time, limit int) ([]WebhookDelivery, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	var deliveries []WebhookDelivery
+	for i := range s.data.Webhooks {
+		if len(deliveries) == limit {
+			break
+		}
+		if s.data.Webhooks[i].NextAttemptAt.After(now) {
+			continue
+		}
+		s.data.Webhooks[i].NextAttemptAt = until
+		deliveries = append(deliveries, s.data.Webhooks[i])
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	return deliveries, s.commit()
+}
+
+func (s *MemoryStore) UpdateWebhook(delivery *WebhookDelivery) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	for i := range s.data.Webhooks {
+		if s.data.Webhooks[i].ID == delivery.ID {
+			stored := &s.data.Webhooks[i]
+			stored.Attempts = delivery.Attempts
+			stored.NextAttemptAt = delivery.NextAttemptAt
+			stored.LastError = delivery.LastError
+		}
+	}
+	return s.commit()
+}
+
+func (s *MemoryStore) DeleteWebhook(deliveryID int) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	s.data.Webhooks = slices.DeleteFunc(s.data.Webhooks, func(delivery WebhookDelivery) bool {
+		return delivery.ID == deliveryID
+	})
+	return s.commit()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is one Prometheus metric family in the text exposition format.
+type metric struct {
+	name    string
+	kind    string // counter or gauge
+	help    string
+	samples []sample
+}
+
+type sample struct {
+	labels string // already formatted, e.g. `phase="focus"`
+	value  float64
+}
+
+func (m metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	for _, s := range m.samples {
+		name := m.name
+		if s.labels != "" {
+			name += "{" + s.labels + "}"
+		}
+		fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(s.value, 'f', -1, 64))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// collectMetrics derives the metrics from every split in the store and the
+// live timer. Totals drop when sessions are deleted, which Prometheus
+// treats as a counter reset.
+func collectMetrics(store Store) ([]metric, error) {
+	stats, err := collectStats(store, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	status, daemonUp, err := timerStatus(store)
+	if err != nil {
+		return nil, err
+	}
+
+	running := status.State == "running"
+	paused := status.State == "paused"
+	active := running || paused
+	remaining := 0
+	if active && !status.CountingUp {
+		remaining = status.RemainingSeconds
+	}
+
+	return []metric{
+		{"romodoro_sessions_total", "counter", "Sessions started.",
+			[]sample{{"", float64(stats.Sessions)}}},
+		{"romodoro_splits_total", "counter", "Finished splits by how they ended.",
+			[]sample{
+				{`status="completed"`, float64(stats.SplitsCompleted)},
+				{`status="cancelled"`, float64(stats.SplitsCancelled)},
+			}},
+		{"romodoro_focus_seconds_total", "counter", "Time spent focusing, overtime included.",
+			[]sample{{"", float64(stats.FocusSeconds)}}},
+		{"romodoro_rest_seconds_total", "counter", "Time spent resting, overtime included.",
+			[]sample{{"", float64(stats.RestSeconds)}}},
+		{"romodoro_overtime_seconds_total", "counter", "Time spent past the end of soft-end phases.",
+			[]sample{
+				{`phase="focus"`, float64(stats.FocusOvertimeSeconds)},
+				{`phase="rest"`, float64(stats.RestOvertimeSeconds)},
+			}},
+		{"romodoro_interruptions_total", "counter", "Pauses of running splits.",
+			[]sample{{"", float64(stats.Interruptions)}}},
+		{"romodoro_daemon_up", "gauge", "Whether the background daemon is running.",
+			[]sample{{"", boolValue(daemonUp)}}},
+		{"romodoro_timer_state", "gauge", "The timer state; exactly one is 1.",
+			[]sample{
+				{`state="idle"`, boolValue(!active)},
+				{`state="running"`, boolValue(running)},
+				{`state="paused"`, boolValue(paused)},
+			}},
+		{"romodoro_timer_phase", "gauge", "The phase of the current split; both are 0 when idle.",
+			[]sample{
+				{`phase="focus"`, boolValue(active && status.Phase == "focus")},
+				{`phase="rest"`, boolValue(active && status.Phase == "rest")},
+			}},
+		{"romodoro_timer_remaining_seconds", "gauge", "Time left in the current phase; 0 when idle or counting up.",
+			[]sample{{"", float64(remaining)}}},
+		{"romodoro_timer_elapsed_seconds", "gauge", "Time spent in the current phase.",
+			[]sample{{"", float64(status.ElapsedSeconds)}}},
+	}, nil
+}
+
+func (api *apiServer) metrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := collectMetrics(api.store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var body strings.Builder
+	for _, m := range metrics {
+		m.write(&body)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, body.String())
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type migration struct {
+	version     int
+	description string
+	up          string
+}
+
+// migrations must stay ordered by version. Never edit a migration that has
+// been released; append a new one instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create sessions and pomodoro_splits tables",
+		up: `
+			CREATE TABLE IF NOT EXISTS sessions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				total_focus_seconds INTEGER DEFAULT 0,
+				total_rest_seconds INTEGER DEFAULT 0
+			);
+
+			CREATE TABLE IF NOT EXISTS pomodoro_splits (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				session_id INTEGER NOT NULL,
+				focus_minutes INTEGER NOT NULL,
+				rest_minutes INTEGER NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME,
+				status TEXT NOT NULL DEFAULT 'in_progress',
+				actual_focus_seconds INTEGER DEFAULT 0,
+				actual_rest_seconds INTEGER DEFAULT 0,
+				FOREIGN KEY (session_id) REFERENCES sessions (id)
+			);
+		`,
+	},
+	{
+		version:     2,
+		description: "record cycle position on pomodoro_splits",
+		up: `
+			ALTER TABLE pomodoro_splits ADD COLUMN cycle_position INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN cycle_length INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		version:     3,
+		description: "create presets table with built-in presets",
+		up: `
+			CREATE TABLE presets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				focus_minutes INTEGER NOT NULL,
+				rest_minutes INTEGER NOT NULL,
+				long_break_minutes INTEGER NOT NULL DEFAULT 0,
+				cycle_length INTEGER NOT NULL DEFAULT 1,
+				builtin INTEGER NOT NULL DEFAULT 0
+			);
+
+			INSERT INTO presets (name, focus_minutes, rest_minutes, long_break_minutes, cycle_length, builtin)
+			VALUES
+				('Pomodoro', 25, 5, 15, 4, 1),
+				('Deep Work', 50, 10, 10, 1, 1),
+				('Ultradian', 90, 20, 20, 1, 1);
+		`,
+	},
+	{
+		version:     4,
+		description: "add auto-continue settings to sessions and presets",
+		up: `
+			ALTER TABLE sessions ADD COLUMN auto_continue INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE sessions ADD COLUMN auto_continue_grace_seconds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE presets ADD COLUMN auto_continue INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE presets ADD COLUMN auto_continue_grace_seconds INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		version:     5,
+		description: "add timer mode to pomodoro_splits",
+		up: `
+			ALTER TABLE pomodoro_splits ADD COLUMN mode TEXT NOT NULL DEFAULT 'countdown';
+		`,
+	},
+	{
+		version:     6,
+		description: "record extend, skip and restart adjustments on pomodoro_splits",
+		up: `
+			ALTER TABLE pomodoro_splits ADD COLUMN focus_extension_seconds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN rest_extension_seconds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN focus_skipped INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN rest_skipped INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN restarts INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		version:     7,
+		description: "create interruptions table",
+		up: `
+			CREATE TABLE interruptions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				split_id INTEGER NOT NULL,
+				phase TEXT NOT NULL,
+				started_at DATETIME NOT NULL,
+				ended_at DATETIME,
+				duration_seconds INTEGER NOT NULL DEFAULT 0,
+				kind TEXT NOT NULL DEFAULT '',
+				reason TEXT NOT NULL DEFAULT '',
+				FOREIGN KEY (split_id) REFERENCES pomodoro_splits (id)
+			);
+
+			CREATE INDEX interruptions_split_id ON interruptions (split_id);
+		`,
+	},
+	{
+		version:     8,
+		description: "add soft-end overtime tracking",
+		up: `
+			ALTER TABLE sessions ADD COLUMN soft_end INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN focus_overtime_seconds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE pomodoro_splits ADD COLUMN rest_overtime_seconds INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		version:     9,
+		description: "create webhook outbox",
+		up: `
+			CREATE TABLE webhook_outbox (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url TEXT NOT NULL,
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at DATETIME NOT NULL,
+				last_error TEXT NOT NULL DEFAULT ''
+			);
+
+			CREATE INDEX idx_webhook_outbox_next_attempt_at ON webhook_outbox (next_attempt_at);
+		`,
+	},
+	{
+		version:     10,
+		description: "record which process is timing a split",
+		up: `
+			ALTER TABLE pomodoro_splits ADD COLUMN owner_pid INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		version:     11,
+		description: "create split_events table",
+		up: `
+			CREATE TABLE split_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				split_id INTEGER NOT NULL,
+				kind TEXT NOT NULL,
+				at DATETIME NOT NULL,
+				seconds INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (split_id) REFERENCES pomodoro_splits (id)
+			);
+
+			CREATE INDEX split_events_split_id ON split_events (split_id);
+		`,
+	},
+}
+
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// migrate brings the database up to latestSchemaVersion. All pending
+// migrations run in a single transaction, so a failure leaves the
+// database exactly as it was.
+func migrate(db *sql.DB) error {
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	latest := latestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d); please upgrade romodoro", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Another process may have migrated while we waited for the lock
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&current); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.Exec(m.up); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		_, err := tx.Exec(
+			"INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+			m.version, m.description, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return tx.Commit()
+}
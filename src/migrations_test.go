@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baselineSchema is the schema created before migrations existed.
+const baselineSchema = `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		end_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		total_focus_seconds INTEGER DEFAULT 0,
+		total_rest_seconds INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS pomodoro_splits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		focus_minutes INTEGER NOT NULL,
+		rest_minutes INTEGER NOT NULL,
+		start_time DATETIME NOT NULL,
+		end_time DATETIME,
+		status TEXT NOT NULL DEFAULT 'in_progress',
+		actual_focus_seconds INTEGER DEFAULT 0,
+		actual_rest_seconds INTEGER DEFAULT 0,
+		FOREIGN KEY (session_id) REFERENCES sessions (id)
+	);
+`
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	_, err := db.Exec(
+		"INSERT INTO sessions (name, start_time, end_time, total_focus_seconds, total_rest_seconds) VALUES (?, ?, ?, ?, ?)",
+		"Writing", start, end, 1500, 300,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO pomodoro_splits
+			(session_id, focus_minutes, rest_minutes, start_time, end_time, status, actual_focus_seconds, actual_rest_seconds)
+		VALUES (1, 25, 5, ?, ?, 'completed', 1500, 300)`,
+		start, end,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	version, err := schemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", version, latestSchemaVersion())
+	}
+
+	// Running again is a no-op
+	if err := migrate(db); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+
+	store := &SQLiteStore{db: db}
+	session, err := store.GetSession(1)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if session.Name != "Writing" || session.TotalFocusSeconds != 1500 || session.TotalRestSeconds != 300 {
+		t.Errorf("session = %+v", session)
+	}
+	if !session.StartTime.Equal(start) {
+		t.Errorf("session start = %v, want %v", session.StartTime, start)
+	}
+
+	splits, err := store.GetSessionSplits(1)
+	if err != nil {
+		t.Fatalf("GetSessionSplits: %v", err)
+	}
+	if len(splits) != 1 {
+		t.Fatalf("got %d splits, want 1", len(splits))
+	}
+	split := splits[0]
+	if split.Status != "completed" || split.ActualFocusSeconds != 1500 || split.ActualRestSeconds != 300 {
+		t.Errorf("split = %+v", split)
+	}
+	if split.Mode != "countdown" || split.CyclePosition != 0 || split.Restarts != 0 {
+		t.Errorf("new split columns not defaulted: %+v", split)
+	}
+
+	presets, err := store.ListPresets()
+	if err != nil {
+		t.Fatalf("ListPresets: %v", err)
+	}
+	if len(presets) != len(builtinPresets) {
+		t.Errorf("got %d presets, want the %d built-in ones", len(presets), len(builtinPresets))
+	}
+}
+
+func TestMigrateRejectsNewerDatabase(t *testing.T) {
+	db := openTestDB(t)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(
+		"INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+		latestSchemaVersion()+1, "from the future", time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrate(db)
+	if err == nil || !strings.Contains(err.Error(), "newer than this binary") {
+		t.Fatalf("migrate = %v, want a newer-schema error", err)
+	}
+}
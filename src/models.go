@@ -1,15 +1,15 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+
+	"romodoro/timer"
 )
 
 type AppState int
@@ -20,66 +20,117 @@ const (
 	StateTimer
 	StatePaused
 	StateSessionBrowser
-)
-
-type TimerPhase int
-
-const (
-	PhaseFocus TimerPhase = iota
-	PhaseRest
+	StateRecovery
+	StateAutoContinue
+	StateAttached
 )
 
 type App struct {
-	db           *sql.DB
+	store        Store
 	state        AppState
 	session      *Session
 	currentSplit *PomodoroSplit
 
 	// Timer state
-	phase            TimerPhase
-	totalSeconds     int
-	remainingSeconds int
-	isPaused         bool
+	timer *timer.Timer
 
 	// UI components
 	textInput textinput.Model
 	progress  progress.Model
 
 	// Input state
-	focusInput string
-	restInput  string
-	inputStep  int // 0: focus, 1: rest
+	focusInput     string
+	restInput      string
+	cycleInput     string
+	longBreakInput string
+	inputStep      int // 0: focus, 1: rest, 2: cycle length, 3: long break, 4: preset name
+
+	// Preset picker state
+	presets        []Preset
+	selectedPreset int
+	choosingPreset bool
+
+	// Cycle state
+	cycle cyclePlan
+
+	// Auto-continue state
+	activePreset   *Preset
+	autoContinueAt time.Time
 
 	// Session browser state
 	sessions        []Session
 	selectedSession int
 
+	// Interruption state
+	interruption   *Interruption
+	enteringReason bool
+
+	// Crash recovery state
+	orphans []PomodoroSplit
+
+	// hooksFrom is when a recovered split was picked up. Earlier events
+	// were reported by whoever was timing the split then.
+	hooksFrom time.Time
+
+	// Background timer state, while attached to a daemon
+	daemon        *daemonClient
+	daemonUpdates <-chan TimerStatus
+	attached      TimerStatus
+	notice        string
+
+	configWatcher *configWatcher
+	notifyErr     error
+
 	width  int
 	height int
 }
 
 type TickMsg time.Time
 
-func NewApp(db *sql.DB) *App {
+// cyclePlan describes a classic Pomodoro set: length focus/rest splits,
+// the last of which ends with a long break instead of a regular rest.
+type cyclePlan struct {
+	focusMinutes     int
+	restMinutes      int
+	longBreakMinutes int
+	length           int
+	position         int // 1-based position of the running split
+
+	// flowtime splits count focus up until stopped and earn a
+	// proportional rest.
+	flowtime bool
+}
+
+func (c cyclePlan) restFor(position int) int {
+	if c.length > 1 && position == c.length {
+		return c.longBreakMinutes
+	}
+	return c.restMinutes
+}
+
+func NewApp(store Store) *App {
 	ti := textinput.New()
-	ti.Placeholder = "Enter focus time in minutes..."
 	ti.Focus()
 	ti.CharLimit = 3
 	ti.Width = 20
 
-	prog := progress.New(progress.WithDefaultGradient())
-	prog.Width = 60
-
-	return &App{
-		db:        db,
+	app := &App{
+		store:     store,
 		state:     StateMainMenu,
+		timer:     timer.New(timer.SystemClock{}),
 		textInput: ti,
-		progress:  prog,
 	}
+	app.applyConfig()
+	if path, err := configPath(); err == nil {
+		app.configWatcher = newConfigWatcher(path)
+	}
+	app.loadOrphans()
+
+	return app
 }
 
 func (m *App) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, configCheckCmd(), webhookCheckCmd(m.store))
 }
 
 func (m *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -87,15 +138,20 @@ func (m *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.progress.Width = min(60, msg.Width-4)
+		m.progress.Width = min(currentConfig().Theme.ProgressWidth, msg.Width-4)
 		return m, nil
 
+	case configCheckMsg:
+		return m.updateConfigCheck()
+
+	case webhookCheckMsg:
+		return m, webhookCheckCmd(m.store)
+
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		if key := msg.String(); currentConfig().Keymap.Quit.has(key) && (key == "ctrl+c" || !m.typingText()) {
 			if m.session != nil {
 				m.saveCurrentState()
-				CloseSession(m.db, m.session.ID)
+				m.store.CloseSession(m.session.ID)
 			}
 			return m, tea.Quit
 		}
@@ -111,70 +167,132 @@ func (m *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updatePaused(msg)
 		case StateSessionBrowser:
 			return m.updateSessionBrowser(msg)
+		case StateRecovery:
+			return m.updateRecovery(msg)
+		case StateAutoContinue:
+			return m.updateAutoContinue(msg)
+		case StateAttached:
+			return m.updateAttached(msg)
 		}
 
+	case daemonStatusMsg:
+		return m.updateDaemonStatus(msg)
+
+	case daemonClosedMsg:
+		return m.updateDaemonClosed(msg)
+
+	case notifyResultMsg:
+		m.notifyErr = msg.err
+		return m, nil
+
 	case TickMsg:
-		if m.state == StateTimer && !m.isPaused {
+		if m.state == StateTimer {
 			return m.updateTick()
 		}
+		if m.state == StateAutoContinue {
+			return m.updateAutoContinueTick()
+		}
 	}
 
 	return m, nil
 }
 
+// typingText reports whether the focused input accepts free text, in which
+// case single-letter shortcuts must not fire.
+func (m *App) typingText() bool {
+	switch m.state {
+	case StateTimerSetup:
+		return !m.choosingPreset && m.inputStep == 4
+	case StatePaused:
+		return m.enteringReason
+	}
+	return false
+}
+
 func (m *App) updateMainMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "1":
+	m.notice = ""
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.ContinueSession.has(key):
 		// Continue last session
-		session, err := GetLastSession(m.db)
+		session, err := m.store.GetLastSession()
 		if err != nil {
 			// No previous session, create new one
 			return m.createNewSession()
 		}
 		m.session = session
 		m.state = StateTimerSetup
-		m.textInput.Placeholder = "Enter focus time in minutes..."
-		m.textInput.SetValue("")
+		m.resetTimerSetup()
 		return m, textinput.Blink
-	case "2":
+	case keys.BrowseSessions.has(key):
 		return m.loadSessionBrowser()
-	case "3":
+	case keys.NewSession.has(key):
 		return m.createNewSession()
+	case keys.Attach.has(key):
+		return m.attachDaemon()
 	}
 	return m, nil
 }
 
 func (m *App) createNewSession() (tea.Model, tea.Cmd) {
-	sessionName := fmt.Sprintf("Session_%s", time.Now().Format("2006-01-02_15-04-05"))
-	session, err := CreateSession(m.db, sessionName)
+	session, err := m.store.CreateSession(defaultSessionName(time.Now()))
 	if err != nil {
 		return m, tea.Quit
 	}
 	m.session = session
+	emitEvent(m.store, HookSessionCreated, session, nil)
 	m.state = StateTimerSetup
-	m.textInput.Placeholder = "Enter focus time in minutes..."
-	m.textInput.SetValue("")
+	m.resetTimerSetup()
 	return m, textinput.Blink
 }
 
+func defaultSessionName(now time.Time) string {
+	return fmt.Sprintf("Session_%s", now.Format("2006-01-02_15-04-05"))
+}
+
 func (m *App) updateTimerSetup(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
-	switch msg.String() {
-	case "enter":
-		if m.inputStep == 0 {
+	if m.choosingPreset {
+		return m.updatePresetPicker(msg)
+	}
+	if m.inputStep == 4 {
+		return m.updatePresetName(msg)
+	}
+
+	switch key := msg.String(); {
+	case key == "enter":
+		switch m.inputStep {
+		case 0:
 			// Focus time entered
 			m.focusInput = m.textInput.Value()
 			m.inputStep = 1
-			m.textInput.Placeholder = "Enter rest time in minutes..."
+			m.textInput.Placeholder = fmt.Sprintf("Enter rest time in minutes (Enter for %d)...", currentConfig().Timer.RestMinutes)
 			m.textInput.SetValue("")
 			return m, textinput.Blink
-		} else {
+		case 1:
 			// Rest time entered
 			m.restInput = m.textInput.Value()
+			m.inputStep = 2
+			m.textInput.Placeholder = "Pomodoros per cycle (Enter for 1)..."
+			m.textInput.SetValue("")
+			return m, textinput.Blink
+		case 2:
+			// Cycle length entered
+			m.cycleInput = m.textInput.Value()
+			if length, err := strconv.Atoi(m.cycleInput); err == nil && length > 1 {
+				m.inputStep = 3
+				m.textInput.Placeholder = fmt.Sprintf("Enter long break in minutes (Enter for %d)...", currentConfig().Timer.LongBreakMinutes)
+				m.textInput.SetValue("")
+				return m, textinput.Blink
+			}
+			return m.startTimer()
+		default:
+			// Long break entered
+			m.longBreakInput = m.textInput.Value()
 			return m.startTimer()
 		}
-	case "m", "M":
+	case currentConfig().Keymap.Menu.has(key):
 		m.state = StateMainMenu
 		return m, nil
 	}
@@ -183,57 +301,164 @@ func (m *App) updateTimerSetup(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m *App) resetTimerSetup() {
+	m.inputStep = 0
+	m.focusInput = ""
+	m.restInput = ""
+	m.cycleInput = ""
+	m.longBreakInput = ""
+	m.textInput.CharLimit = 3
+	m.textInput.Placeholder = fmt.Sprintf("Enter focus time in minutes (Enter for %d)...", currentConfig().Timer.FocusMinutes)
+	m.textInput.SetValue("")
+	m.loadPresets()
+}
+
+func (m *App) invalidSetupInput(step int, placeholder string) (tea.Model, tea.Cmd) {
+	m.inputStep = step
+	m.textInput.Placeholder = placeholder
+	m.textInput.SetValue("")
+	return m, textinput.Blink
+}
+
 func (m *App) startTimer() (tea.Model, tea.Cmd) {
+	// Empty answers take the configured defaults
+	defaults := currentConfig().Timer
+	if m.focusInput == "" {
+		m.focusInput = strconv.Itoa(defaults.FocusMinutes)
+	}
+	if m.restInput == "" {
+		m.restInput = strconv.Itoa(defaults.RestMinutes)
+	}
+	if m.longBreakInput == "" {
+		m.longBreakInput = strconv.Itoa(defaults.LongBreakMinutes)
+	}
+
 	focusMinutes, err := strconv.Atoi(m.focusInput)
 	if err != nil || focusMinutes <= 0 {
-		m.inputStep = 0
-		m.textInput.Placeholder = "Invalid focus time. Enter focus time in minutes..."
-		m.textInput.SetValue("")
-		return m, textinput.Blink
+		return m.invalidSetupInput(0, "Invalid focus time. Enter focus time in minutes...")
 	}
 
 	restMinutes, err := strconv.Atoi(m.restInput)
 	if err != nil || restMinutes < 0 {
-		m.inputStep = 1
-		m.textInput.Placeholder = "Invalid rest time. Enter rest time in minutes..."
-		m.textInput.SetValue("")
-		return m, textinput.Blink
+		return m.invalidSetupInput(1, "Invalid rest time. Enter rest time in minutes...")
+	}
+
+	cycleLength := 1
+	if m.cycleInput != "" {
+		cycleLength, err = strconv.Atoi(m.cycleInput)
+		if err != nil || cycleLength <= 0 {
+			return m.invalidSetupInput(2, "Invalid cycle length. Pomodoros per cycle (Enter for 1)...")
+		}
+	}
+
+	longBreakMinutes := restMinutes
+	if cycleLength > 1 {
+		longBreakMinutes, err = strconv.Atoi(m.longBreakInput)
+		if err != nil || longBreakMinutes < 0 {
+			return m.invalidSetupInput(3, "Invalid long break. Enter long break in minutes...")
+		}
+	}
+
+	m.cycle = cyclePlan{
+		focusMinutes:     focusMinutes,
+		restMinutes:      restMinutes,
+		longBreakMinutes: longBreakMinutes,
+		length:           cycleLength,
+	}
+	m.activePreset = nil
+
+	// Offer to keep these values as a preset before starting
+	m.inputStep = 4
+	m.textInput.CharLimit = 30
+	m.textInput.Placeholder = "Save as preset? Enter a name, or leave empty..."
+	m.textInput.SetValue("")
+	return m, textinput.Blink
+}
+
+// startSplit records and starts the split at the given cycle position.
+func (m *App) startSplit(position int) (tea.Model, tea.Cmd) {
+	split := PomodoroSplit{
+		SessionID:    m.session.ID,
+		FocusMinutes: m.cycle.focusMinutes,
+		RestMinutes:  m.cycle.restFor(position),
+		Mode:         SplitModeCountdown,
+	}
+	if m.cycle.flowtime {
+		split.FocusMinutes = 0
+		split.RestMinutes = 0
+		split.Mode = SplitModeFlowtime
+	}
+	if m.cycle.length > 1 {
+		split.CyclePosition = position
+		split.CycleLength = m.cycle.length
 	}
 
-	split, err := CreatePomodoroSplit(m.db, m.session.ID, focusMinutes, restMinutes)
+	created, err := m.store.CreatePomodoroSplit(split)
 	if err != nil {
 		return m, tea.Quit
 	}
-
-	m.currentSplit = split
-	m.phase = PhaseFocus
-	m.totalSeconds = focusMinutes * 60
-	m.remainingSeconds = m.totalSeconds
+	claimSplit(m.store, created.ID)
+
+	m.cycle.position = position
+	m.currentSplit = created
+	m.hooksFrom = time.Time{}
+	plan := splitPlan(created)
+	plan.SoftEnd = m.session.SoftEnd
+	m.timer.StartAt(created.StartTime, plan)
+	m.drainTimerEvents()
 	m.state = StateTimer
-	m.isPaused = false
-
-	// Reset for next split
-	m.inputStep = 0
-	m.focusInput = ""
-	m.restInput = ""
 
 	return m, m.tickCmd()
 }
 
+// splitPlan is the timer plan for a split as planned. Extensions are
+// replayed from its split events.
+func splitPlan(split *PomodoroSplit) timer.Plan {
+	return timer.Plan{
+		Focus:   time.Duration(split.FocusMinutes*60) * time.Second,
+		Rest:    time.Duration(split.RestMinutes*60) * time.Second,
+		CountUp: split.Mode == SplitModeFlowtime,
+	}
+}
+
 func (m *App) updateTimer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "p", "P":
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.AutoContinue.has(key):
+		m.toggleSessionAutoContinue()
+		return m, nil
+	case keys.Finish.has(key):
+		if m.timer.CountingUp() {
+			now := wallNow()
+			m.timer.EndPhaseAt(now)
+			m.recordSplitEvent(SplitEventEnded, now, 0)
+			m.drainTimerEvents()
+		}
+		return m, nil
+	case keys.Acknowledge.has(key):
+		return m.acknowledgeOvertime()
+	case keys.SoftEnd.has(key):
+		m.toggleSoftEnd()
+		return m, nil
+	case keys.Extend.has(key):
+		return m.extendPhase()
+	case keys.Skip.has(key):
+		return m.skipPhase()
+	case keys.Restart.has(key):
+		return m.restartPhase()
+	case keys.Pause.has(key):
 		m.state = StatePaused
-		m.isPaused = true
+		m.timer.Pause()
+		m.drainTimerEvents()
+		m.beginInterruption()
 		return m, nil
-	case "b", "B":
+	case keys.Back.has(key):
 		m.saveCurrentState()
 		m.refreshSessionData() // Add this line
 		m.state = StateTimerSetup
-		m.textInput.Placeholder = "Enter focus time in minutes..."
-		m.textInput.SetValue("")
+		m.resetTimerSetup()
 		return m, textinput.Blink
-	case "m", "M":
+	case keys.Menu.has(key):
 		m.saveCurrentState()
 		m.state = StateMainMenu
 		return m, nil
@@ -241,20 +466,129 @@ func (m *App) updateTimer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// extendPhase adds the configured step to the running phase. Like every
+// change to a running phase, it is logged as a split event straight away,
+// so the planned minutes stay untouched and other processes and crash
+// recovery rebuild the real deadline.
+func (m *App) extendPhase() (tea.Model, tea.Cmd) {
+	if m.timer.CountingUp() {
+		return m, nil
+	}
+	step := time.Duration(currentConfig().Timer.ExtendMinutes) * time.Minute
+	now := wallNow()
+	m.timer.ExtendAt(now, step)
+	m.recordSplitEvent(SplitEventExtended, now, int(step.Seconds()))
+	m.drainTimerEvents()
+
+	if m.timer.Phase() == timer.Focus {
+		m.currentSplit.FocusExtensionSeconds += int(step.Seconds())
+	} else {
+		m.currentSplit.RestExtensionSeconds += int(step.Seconds())
+	}
+	m.store.UpdatePomodoroSplit(m.currentSplit)
+	return m, nil
+}
+
+func (m *App) skipPhase() (tea.Model, tea.Cmd) {
+	// Catch up first so the skip applies to the phase that is running now
+	now := wallNow()
+	m.timer.TickAt(now)
+	if m.timer.Phase() == timer.Focus {
+		m.currentSplit.FocusSkipped = true
+	} else {
+		m.currentSplit.RestSkipped = true
+	}
+	m.store.UpdatePomodoroSplit(m.currentSplit)
+
+	m.timer.EndPhaseAt(now)
+	m.recordSplitEvent(SplitEventEnded, now, 0)
+	if finished, _ := m.drainTimerEvents(); finished {
+		return m.afterSplit()
+	}
+	return m, nil
+}
+
+// acknowledgeOvertime ends a soft-end phase that has run past its time.
+func (m *App) acknowledgeOvertime() (tea.Model, tea.Cmd) {
+	if m.timer.Overtime() <= 0 {
+		return m, nil
+	}
+	now := wallNow()
+	m.timer.EndPhaseAt(now)
+	m.recordSplitEvent(SplitEventEnded, now, 0)
+	if finished, _ := m.drainTimerEvents(); finished {
+		return m.afterSplit()
+	}
+	return m, nil
+}
+
+func (m *App) toggleSoftEnd() {
+	if m.session == nil {
+		return
+	}
+	enabled := !m.session.SoftEnd
+	if err := m.store.SetSessionSoftEnd(m.session.ID, enabled); err != nil {
+		return
+	}
+	m.session.SoftEnd = enabled
+
+	now := wallNow()
+	m.timer.TickAt(now)
+	m.timer.SetSoftEnd(enabled)
+	kind := SplitEventSoftEndOff
+	if enabled {
+		kind = SplitEventSoftEndOn
+	}
+	m.recordSplitEvent(kind, now, 0)
+}
+
+// recordSplitEvent logs a change to the running split, so the timer can be
+// rebuilt from the store.
+func (m *App) recordSplitEvent(kind string, at time.Time, seconds int) {
+	if m.currentSplit != nil {
+		recordSplitEvent(m.store, m.currentSplit.ID, kind, at, seconds)
+	}
+}
+
+func (m *App) restartPhase() (tea.Model, tea.Cmd) {
+	now := wallNow()
+	m.timer.RestartAt(now)
+	m.recordSplitEvent(SplitEventRestarted, now, 0)
+	m.drainTimerEvents()
+
+	m.currentSplit.Restarts++
+	m.store.UpdatePomodoroSplit(m.currentSplit)
+	return m, nil
+}
+
 func (m *App) updatePaused(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "s", "S", "c", "C":
+	if m.enteringReason {
+		return m.updateInterruptionReason(msg)
+	}
+
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.Internal.has(key):
+		m.setInterruptionKind(InterruptionInternal)
+		return m, nil
+	case keys.External.has(key):
+		m.setInterruptionKind(InterruptionExternal)
+		return m, nil
+	case keys.Reason.has(key):
+		return m.beginInterruptionReason()
+	case keys.Resume.has(key):
+		m.endInterruption()
 		m.state = StateTimer
-		m.isPaused = false
+		m.timer.Resume()
+		m.drainTimerEvents()
 		return m, m.tickCmd()
-	case "b", "B":
+	case keys.Back.has(key):
 		m.saveCurrentState()
 		m.refreshSessionData() // Add this line
 		m.state = StateTimerSetup
-		m.textInput.Placeholder = "Enter focus time in minutes..."
-		m.textInput.SetValue("")
+		m.resetTimerSetup()
 		return m, textinput.Blink
-	case "m", "M":
+	case keys.Menu.has(key):
 		m.saveCurrentState()
 		m.state = StateMainMenu
 		return m, nil
@@ -267,25 +601,15 @@ func (m *App) refreshSessionData() {
 		return
 	}
 
-	// Get updated session data from database
-	var session Session
-	var endTime time.Time
-
-	err := m.db.QueryRow(`
-		SELECT id, name, start_time, end_time, total_focus_seconds, total_rest_seconds
-		FROM sessions
-		WHERE id = ?
-	`, m.session.ID).Scan(&session.ID, &session.Name, &session.StartTime, &endTime,
-		&session.TotalFocusSeconds, &session.TotalRestSeconds)
-
+	// Get updated session data from the store
+	session, err := m.store.GetSession(m.session.ID)
 	if err == nil {
-		session.EndTime = &endTime
-		m.session = &session
+		m.session = session
 	}
 }
 
 func (m *App) loadSessionBrowser() (tea.Model, tea.Cmd) {
-	sessions, err := GetAllSessions(m.db)
+	sessions, err := m.store.GetAllSessions()
 	if err != nil {
 		return m, tea.Quit
 	}
@@ -296,26 +620,37 @@ func (m *App) loadSessionBrowser() (tea.Model, tea.Cmd) {
 }
 
 func (m *App) updateSessionBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "up", "k":
+	m.notice = ""
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case key == "up" || key == "k":
 		if m.selectedSession > 0 {
 			m.selectedSession--
 		}
-	case "down", "j":
+	case key == "down" || key == "j":
 		if m.selectedSession < len(m.sessions)-1 {
 			m.selectedSession++
 		}
-	case "x", "X":
+	case keys.Delete.has(key):
 		if len(m.sessions) > 0 {
 			sessionID := m.sessions[m.selectedSession].ID
-			err := DeleteSession(m.db, sessionID)
+			err := m.store.DeleteSession(sessionID)
 			if err != nil {
 				return m, nil
 			}
 			// Reload sessions
 			return m.loadSessionBrowser()
 		}
-	case "b", "B", "m", "M":
+	case keys.Export.has(key):
+		if len(m.sessions) > 0 {
+			path, err := exportSession(m.store, m.sessions[m.selectedSession])
+			if err != nil {
+				m.notice = "Export failed: " + err.Error()
+			} else {
+				m.notice = "Exported the splits to " + path
+			}
+		}
+	case keys.Back.has(key), keys.Menu.has(key):
 		m.state = StateMainMenu
 		return m, nil
 	}
@@ -323,46 +658,89 @@ func (m *App) updateSessionBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m *App) updateTick() (tea.Model, tea.Cmd) {
-	m.remainingSeconds--
-
-	if m.remainingSeconds <= 0 {
-		// Phase completed
-		if m.phase == PhaseFocus {
-			// Focus phase completed, start rest
-			m.currentSplit.ActualFocusSeconds = m.totalSeconds
-			m.phase = PhaseRest
-			m.totalSeconds = m.currentSplit.RestMinutes * 60
-			m.remainingSeconds = m.totalSeconds
-			m.playSound()
-			return m, m.tickCmd()
-		} else {
-			// Rest phase completed, split finished
-			m.currentSplit.ActualRestSeconds = m.totalSeconds
-			m.finishSplit()
-			m.playSound()
-			m.state = StateTimerSetup
-			m.textInput.Placeholder = "Enter focus time in minutes..."
-			m.textInput.SetValue("")
-			return m, textinput.Blink
-		}
+	m.timer.Tick()
+	finished, alarm := m.drainTimerEvents()
+	var notify tea.Cmd
+	if alarm != nil {
+		notify = m.notify(*alarm)
+	}
+	if !finished {
+		return m, tea.Batch(m.tickCmd(), notify)
+	}
+	model, cmd := m.afterSplit()
+	return model, tea.Batch(cmd, notify)
+}
+
+// afterSplit decides what follows a finished split.
+func (m *App) afterSplit() (tea.Model, tea.Cmd) {
+	// Rest phase completed, move on to the next pomodoro of the cycle
+	if m.cycle.position < m.cycle.length {
+		return m.startSplit(m.cycle.position + 1)
 	}
 
-	return m, m.tickCmd()
+	if enabled, grace := m.autoContinue(); enabled {
+		return m.beginAutoContinue(grace)
+	}
+
+	m.state = StateTimerSetup
+	m.resetTimerSetup()
+	return m, textinput.Blink
 }
 
-func (m *App) finishSplit() {
-	now := time.Now()
-	m.currentSplit.EndTime = &now
+// drainTimerEvents applies everything the timer reported since the last
+// call. It reports whether the split has finished and whether a phase ran
+// out on its own, which is when the user should be notified. Several phases may
+// end in one go after the machine wakes from sleep.
+func (m *App) drainTimerEvents() (finished bool, alarm *timer.Event) {
+	for {
+		select {
+		case event := <-m.timer.Events():
+			// finishSplit clears currentSplit, but hooks still want it
+			split := m.currentSplit
+			switch event.Type {
+			case timer.PhaseStarted:
+				if event.Phase == timer.Rest && m.currentSplit.Mode == SplitModeFlowtime {
+					// Record the rest earned by the flowtime focus
+					m.currentSplit.RestMinutes = int(m.timer.Duration().Minutes())
+					m.store.UpdatePomodoroSplit(m.currentSplit)
+				}
+			case timer.PhaseOvertime:
+				alarm = &event
+			case timer.PhaseEnded:
+				alarm = &event
+				if event.Phase == timer.Rest {
+					m.finishSplit(event.At)
+					finished = true
+				}
+			}
+			if !event.At.Before(m.hooksFrom) {
+				emitEvent(m.store, hookForEvent(event), m.session, split)
+			}
+		default:
+			return finished, alarm
+		}
+	}
+}
+
+func (m *App) finishSplit(endTime time.Time) {
+	m.currentSplit.EndTime = &endTime
 	m.currentSplit.Status = "completed"
+	recordElapsed(m.currentSplit, m.timer)
 
-	UpdatePomodoroSplit(m.db, m.currentSplit)
-	UpdateSessionTotals(m.db, m.session.ID)
+	m.store.UpdatePomodoroSplit(m.currentSplit)
+	m.store.UpdateSessionTotals(m.session.ID)
+	m.currentSplit = nil
 
 	// Refresh session totals
-	session, _ := GetLastSession(m.db)
-	if session != nil && session.ID == m.session.ID {
-		m.session = session
-	}
+	m.refreshSessionData()
+}
+
+// recordElapsed copies the time a timer measured onto its split.
+func recordElapsed(split *PomodoroSplit, t *timer.Timer) {
+	split.ActualFocusSeconds = int(t.FocusElapsed().Seconds())
+	split.ActualRestSeconds = int(t.RestElapsed().Seconds())
+	split.FocusOvertimeSeconds = int(t.FocusOvertime().Seconds())
+	split.RestOvertimeSeconds = int(t.RestOvertime().Seconds())
 }
 
 func (m *App) saveCurrentState() {
@@ -370,19 +748,19 @@ func (m *App) saveCurrentState() {
 		return
 	}
 
+	m.endInterruption()
+	m.timer.Cancel()
+	m.drainTimerEvents()
+
 	now := time.Now()
 	m.currentSplit.EndTime = &now
 	m.currentSplit.Status = "cancelled"
+	recordElapsed(m.currentSplit, m.timer)
 
-	if m.phase == PhaseFocus {
-		m.currentSplit.ActualFocusSeconds = m.totalSeconds - m.remainingSeconds
-	} else {
-		m.currentSplit.ActualFocusSeconds = m.currentSplit.FocusMinutes * 60
-		m.currentSplit.ActualRestSeconds = m.totalSeconds - m.remainingSeconds
-	}
-
-	UpdatePomodoroSplit(m.db, m.currentSplit)
-	UpdateSessionTotals(m.db, m.session.ID)
+	m.store.UpdatePomodoroSplit(m.currentSplit)
+	m.store.UpdateSessionTotals(m.session.ID)
+	emitEvent(m.store, HookSplitCancelled, m.session, m.currentSplit)
+	m.currentSplit = nil
 }
 
 func (m *App) tickCmd() tea.Cmd {
@@ -391,14 +769,6 @@ func (m *App) tickCmd() tea.Cmd {
 	})
 }
 
-func (m *App) playSound() {
-	// Use macOS built-in sound
-	go func() {
-		cmd := exec.Command("afplay", "/System/Library/Sounds/Blow.aiff")
-		cmd.Run()
-	}()
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"romodoro/timer"
+)
+
+// Notification is what the user is told when a phase runs out.
+type Notification struct {
+	Event string // focus_ended, rest_ended or overtime
+	Title string
+	Body  string
+}
+
+// Notifier delivers notifications through one channel.
+type Notifier interface {
+	Name() string
+	Notify(n Notification) error
+}
+
+const (
+	NotifierAuto    = "auto"
+	NotifierBell    = "bell"
+	NotifierSound   = "sound"
+	NotifierDesktop = "desktop"
+	NotifierCommand = "command"
+)
+
+var notifierNames = []string{NotifierAuto, NotifierBell, NotifierSound, NotifierDesktop, NotifierCommand}
+
+// notifyTimeout bounds how long any backend may take.
+const notifyTimeout = 10 * time.Second
+
+func notificationFor(event timer.Event) Notification {
+	switch {
+	case event.Type == timer.PhaseOvertime:
+		return Notification{
+			Event: "overtime",
+			Title: "Time is up",
+			Body:  fmt.Sprintf("The %s phase is running into overtime.", event.Phase),
+		}
+	case event.Phase == timer.Rest:
+		return Notification{Event: "rest_ended", Title: "Break over", Body: "Rest finished. The split is complete."}
+	}
+	return Notification{Event: "focus_ended", Title: "Focus finished", Body: "Time for a break."}
+}
+
+// sendNotification delivers n through every configured backend.
+func sendNotification(n Notification) error {
+	config := currentConfig().Notifications
+	if !config.Enabled {
+		return nil
+	}
+	notifier, err := newNotifier(config)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(n)
+}
+
+type notifyResultMsg struct {
+	err error
+}
+
+// notify sends the notification for event off the UI goroutine.
+func (m *App) notify(event timer.Event) tea.Cmd {
+	return func() tea.Msg {
+		return notifyResultMsg{err: sendNotification(notificationFor(event))}
+	}
+}
+
+// newNotifier builds the backends named in the config. "auto" picks what
+// the platform offers and skips what is missing; a backend named
+// explicitly must be available.
+func newNotifier(config NotificationsConfig) (Notifier, error) {
+	var notifiers multiNotifier
+	for _, name := range config.Backends {
+		if name == NotifierAuto {
+			notifiers = append(notifiers, detectNotifiers(config)...)
+			continue
+		}
+		notifier, err := namedNotifier(name, config)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	if len(notifiers) == 0 {
+		return nil, errors.New("no notification backend is available")
+	}
+	return notifiers, nil
+}
+
+func namedNotifier(name string, config NotificationsConfig) (Notifier, error) {
+	switch name {
+	case NotifierBell:
+		return bellNotifier{}, nil
+	case NotifierSound:
+		return newSoundNotifier(config)
+	case NotifierDesktop:
+		return newDesktopNotifier()
+	case NotifierCommand:
+		if config.Command == "" {
+			return nil, errors.New("the command notifier needs notifications.command")
+		}
+		return commandNotifier{command: config.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier %q", name)
+}
+
+func detectNotifiers(config NotificationsConfig) []Notifier {
+	var notifiers []Notifier
+	if runtime.GOOS != "darwin" {
+		if desktop, err := newDesktopNotifier(); err == nil {
+			notifiers = append(notifiers, desktop)
+		}
+	}
+	if sound, err := newSoundNotifier(config); err == nil {
+		notifiers = append(notifiers, sound)
+	}
+	if len(notifiers) == 0 {
+		notifiers = append(notifiers, bellNotifier{})
+	}
+	return notifiers
+}
+
+// multiNotifier notifies through every backend and reports all failures.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Name() string {
+	names := make([]string, len(m))
+	for i, notifier := range m {
+		names[i] = notifier.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (m multiNotifier) Notify(n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bellNotifier rings the terminal bell on the controlling terminal, or
+// the console on Windows.
+type bellNotifier struct{}
+
+func (bellNotifier) Name() string { return NotifierBell }
+
+func (bellNotifier) Notify(Notification) error {
+	terminal := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		terminal = "CONOUT$"
+	}
+	tty, err := os.OpenFile(terminal, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	_, err = tty.WriteString("\a")
+	return err
+}
+
+// soundNotifier plays a sound file with a command line player.
+type soundNotifier struct {
+	player string
+	sound  string
+}
+
+func (soundNotifier) Name() string { return NotifierSound }
+
+// Players and sounds tried, in order, when the config leaves them empty.
+var soundPlayers = []soundNotifier{
+	{player: "afplay", sound: "/System/Library/Sounds/Blow.aiff"},
+	{player: "paplay", sound: "/usr/share/sounds/freedesktop/stereo/complete.oga"},
+	{player: "aplay", sound: "/usr/share/sounds/alsa/Front_Center.wav"},
+	{player: "powershell", sound: `C:\Windows\Media\Windows Notify System Generic.wav`},
+}
+
+func newSoundNotifier(config NotificationsConfig) (Notifier, error) {
+	for _, candidate := range soundPlayers {
+		if config.Player != "" && config.Player != candidate.player {
+			continue
+		}
+		if _, err := exec.LookPath(candidate.player); err != nil {
+			continue
+		}
+		if config.Sound != "" {
+			candidate.sound = config.Sound
+		}
+		return candidate, nil
+	}
+
+	if config.Player != "" {
+		if _, err := exec.LookPath(config.Player); err != nil {
+			return nil, err
+		}
+		if config.Sound == "" {
+			return nil, fmt.Errorf("notifications.sound is needed for %s", config.Player)
+		}
+		return soundNotifier{player: config.Player, sound: config.Sound}, nil
+	}
+	return nil, errors.New("no sound player found (tried afplay, paplay, aplay and powershell)")
+}
+
+func (s soundNotifier) Notify(Notification) error {
+	if s.player == "powershell" {
+		// Windows has no player that takes a file, but .NET has one
+		script := fmt.Sprintf("(New-Object Media.SoundPlayer '%s').PlaySync()", strings.ReplaceAll(s.sound, "'", "''"))
+		return runNotifyCommand(exec.Command(s.player, "-NoProfile", "-NonInteractive", "-Command", script))
+	}
+	return runNotifyCommand(exec.Command(s.player, s.sound))
+}
+
+// desktopNotifier shows a freedesktop notification over D-Bus, through
+// notify-send or, failing that, gdbus.
+type desktopNotifier struct {
+	notifySend string
+	gdbus      string
+}
+
+func (desktopNotifier) Name() string { return NotifierDesktop }
+
+func newDesktopNotifier() (Notifier, error) {
+	if path, err := exec.LookPath("notify-send"); err == nil {
+		return desktopNotifier{notifySend: path}, nil
+	}
+	if path, err := exec.LookPath("gdbus"); err == nil {
+		return desktopNotifier{gdbus: path}, nil
+	}
+	return nil, errors.New("neither notify-send nor gdbus is installed")
+}
+
+func (d desktopNotifier) Notify(n Notification) error {
+	if d.notifySend != "" {
+		return runNotifyCommand(exec.Command(d.notifySend, "--app-name=Romodoro", n.Title, n.Body))
+	}
+	return runNotifyCommand(exec.Command(d.gdbus, "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"Romodoro", "0", "", n.Title, n.Body, "[]", "{}", "5000"))
+}
+
+// commandNotifier runs a shell command with the notification in its
+// environment.
+type commandNotifier struct {
+	command string
+}
+
+func (commandNotifier) Name() string { return NotifierCommand }
+
+func (c commandNotifier) Notify(n Notification) error {
+	cmd := shellCommand(context.Background(), c.command)
+	cmd.Env = append(os.Environ(),
+		"ROMODORO_EVENT="+n.Event,
+		"ROMODORO_TITLE="+n.Title,
+		"ROMODORO_BODY="+n.Body,
+	)
+	return runNotifyCommand(cmd)
+}
+
+// runNotifyCommand runs cmd with a timeout, putting its stderr into the
+// error so a failure says why.
+func runNotifyCommand(cmd *exec.Cmd) error {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil && stderr.Len() > 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return err
+	case <-time.After(notifyTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("%s timed out", cmd.Path)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// outputSchemaVersion is bumped whenever a field in machine-readable output
+// is renamed, removed or changes meaning. Adding fields does not bump it.
+const outputSchemaVersion = 1
+
+const (
+	OutputTable  = "table"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+)
+
+// envelope wraps every JSON document and NDJSON line so consumers can
+// check the schema before reading data.
+type envelope struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	Data          any    `json:"data"`
+}
+
+type outputFlags struct {
+	output *string
+	json   *bool
+}
+
+func addOutputFlags(flags *flag.FlagSet) outputFlags {
+	return outputFlags{
+		output: flags.String("output", OutputTable, "output `format`: table, json or ndjson"),
+		json:   flags.Bool("json", false, "shorthand for --output json"),
+	}
+}
+
+func (o outputFlags) format() (string, error) {
+	if *o.json {
+		if *o.output != OutputTable && *o.output != OutputJSON {
+			return "", fmt.Errorf("--json conflicts with --output %s", *o.output)
+		}
+		return OutputJSON, nil
+	}
+	switch *o.output {
+	case OutputTable, OutputJSON, OutputNDJSON:
+		return *o.output, nil
+	}
+	return "", fmt.Errorf("unknown output format %q", *o.output)
+}
+
+// writeOne writes a single record. NDJSON and JSON only differ in
+// indentation here.
+func writeOne(w io.Writer, format, recordType string, data any) error {
+	encoder := json.NewEncoder(w)
+	if format == OutputJSON {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(envelope{SchemaVersion: outputSchemaVersion, Type: recordType, Data: data})
+}
+
+// writeList writes records as one JSON document of listType, or as one
+// NDJSON line of itemType per record.
+func writeList[T any](w io.Writer, format, listType, itemType string, items []T) error {
+	if format == OutputJSON {
+		if items == nil {
+			items = []T{}
+		}
+		return writeOne(w, format, listType, items)
+	}
+	for _, item := range items {
+		if err := writeOne(w, format, itemType, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// dataDir is $XDG_DATA_HOME/romodoro, falling back to ~/.local/share.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "romodoro"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "romodoro"), nil
+}
+
+// exportDir is where the session browser writes its exports: next to the
+// database, so they don't land wherever romodoro was started from and
+// follow --db and ROMODORO_DB.
+func exportDir() (string, error) {
+	dir := filepath.Dir(storePath)
+	if storePath == "" {
+		var err error
+		if dir, err = dataDir(); err != nil {
+			return "", err
+		}
+	}
+	dir = filepath.Join(dir, "exports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func storeFileName(backend string) string {
+	if backend == BackendJSON {
+		return "sessions.json"
+	}
+	return "sessions.db"
+}
+
+// resolveStorePath picks the database file: the --db flag, then
+// ROMODORO_DB, then the XDG data directory. Only the default location is
+// ever filled from the legacy ~/romodoro/data directory.
+func resolveStorePath(flagPath, backend string, stderr io.Writer) (string, error) {
+	if flagPath != "" {
+		return flagPath, nil
+	}
+	if path := os.Getenv("ROMODORO_DB"); path != "" {
+		return path, nil
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, storeFileName(backend))
+	if backend == BackendMemory {
+		// Nothing is read from disk, so there is nothing to move
+		return path, nil
+	}
+	if err := moveLegacyStore(path, backend, stderr); err != nil {
+		return "", fmt.Errorf("moving the old database: %w", err)
+	}
+	return path, nil
+}
+
+// moveLegacyStore moves a database from ~/romodoro/data, where releases
+// before XDG support kept it, unless the new location is already in use.
+func moveLegacyStore(path, backend string, stderr io.Writer) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	legacyDir := filepath.Join(home, "romodoro", "data")
+	legacy := filepath.Join(legacyDir, storeFileName(backend))
+
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	// SQLite may have left journal files next to the database
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if _, err := os.Stat(legacy + suffix); err != nil {
+			continue
+		}
+		if err := moveFile(legacy+suffix, path+suffix); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(stderr, "Moved %s to %s\n", legacy, path)
+
+	// Tidy up the old directories if nothing else lives there
+	os.Remove(legacyDir)
+	os.Remove(filepath.Dir(legacyDir))
+	return nil
+}
+
+// moveFile renames src to dst, copying when they are on different devices.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *App) loadPresets() {
+	presets, err := m.store.ListPresets()
+	if err != nil {
+		presets = nil
+	}
+	m.presets = presets
+	m.selectedPreset = 0
+	m.choosingPreset = true
+}
+
+// updatePresetPicker handles the preset list. The row after the last preset
+// switches to entering custom values.
+func (m *App) updatePresetPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case key == "up" || key == "k":
+		if m.selectedPreset > 0 {
+			m.selectedPreset--
+		}
+	case key == "down" || key == "j":
+		if m.selectedPreset < len(m.presets) {
+			m.selectedPreset++
+		}
+	case key == "enter":
+		if m.selectedPreset < len(m.presets) {
+			return m.startPreset(m.presets[m.selectedPreset])
+		}
+		m.choosingPreset = false
+		return m, textinput.Blink
+	case keys.Custom.has(key):
+		m.choosingPreset = false
+		return m, textinput.Blink
+	case keys.AutoContinue.has(key):
+		m.togglePresetAutoContinue()
+	case keys.Flowtime.has(key):
+		return m.startFlowtime()
+	case keys.Delete.has(key):
+		if m.selectedPreset < len(m.presets) && !m.presets[m.selectedPreset].BuiltIn {
+			m.store.DeletePreset(m.presets[m.selectedPreset].ID)
+			selected := m.selectedPreset
+			m.loadPresets()
+			m.selectedPreset = min(selected, len(m.presets))
+		}
+	case keys.Menu.has(key):
+		m.state = StateMainMenu
+	}
+	return m, nil
+}
+
+func (m *App) startPreset(preset Preset) (tea.Model, tea.Cmd) {
+	m.activePreset = &preset
+	m.cycle = cyclePlan{
+		focusMinutes:     preset.FocusMinutes,
+		restMinutes:      preset.RestMinutes,
+		longBreakMinutes: preset.LongBreakMinutes,
+		length:           max(preset.CycleLength, 1),
+	}
+	m.resetTimerSetup()
+	return m.startSplit(1)
+}
+
+func (m *App) startFlowtime() (tea.Model, tea.Cmd) {
+	m.activePreset = nil
+	m.cycle = cyclePlan{length: 1, flowtime: true}
+	m.resetTimerSetup()
+	return m.startSplit(1)
+}
+
+// updatePresetName handles the optional last setup step, which saves the
+// custom values just entered as a preset and then starts the timer.
+func (m *App) updatePresetName(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		name := strings.TrimSpace(m.textInput.Value())
+		if name != "" {
+			_, err := m.store.CreatePreset(Preset{
+				Name:             name,
+				FocusMinutes:     m.cycle.focusMinutes,
+				RestMinutes:      m.cycle.restMinutes,
+				LongBreakMinutes: m.cycle.longBreakMinutes,
+				CycleLength:      m.cycle.length,
+			})
+			if err == ErrPresetExists {
+				return m.invalidSetupInput(4, "Name already taken. Enter another name, or leave empty...")
+			}
+		}
+		m.resetTimerSetup()
+		return m.startSplit(1)
+	case "esc":
+		m.state = StateMainMenu
+		return m, nil
+	}
+
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"romodoro/timer"
+)
+
+// claimSplit makes this process the one timing split. It fails when
+// another process that is still alive owns the split.
+func claimSplit(store Store, splitID int) (bool, error) {
+	owner, err := store.GetSplitOwner(splitID)
+	if err != nil {
+		return false, err
+	}
+	self := os.Getpid()
+	if owner == self {
+		return true, nil
+	}
+	if owner != 0 && processAlive(owner) {
+		return false, nil
+	}
+	return store.SetSplitOwner(splitID, owner, self)
+}
+
+// releaseSplit gives up a split that stays in progress, so the next
+// process can pick it up straight away.
+func releaseSplit(store Store, splitID int) error {
+	_, err := store.SetSplitOwner(splitID, os.Getpid(), 0)
+	return err
+}
+
+// ownedElsewhere reports whether another live process is timing split.
+func ownedElsewhere(store Store, splitID int) bool {
+	owner, err := store.GetSplitOwner(splitID)
+	return err == nil && owner != 0 && owner != os.Getpid() && processAlive(owner)
+}
+
+// splitLive reports whether split is still being timed, given its timer
+// rebuilt by splitTimer. A split owned by a live process is, and so is an
+// unowned one that will run out by itself, such as one started from the
+// command line. Unowned splits that are paused, soft-end or counting up
+// would never finish, so they are orphans.
+func splitLive(store Store, split *PomodoroSplit, t *timer.Timer) bool {
+	owner, err := store.GetSplitOwner(split.ID)
+	if err != nil {
+		return false
+	}
+	if owner != 0 && (owner == os.Getpid() || processAlive(owner)) {
+		return true
+	}
+	return t.State() == timer.StateRunning && !t.CountingUp() && !t.SoftEnd()
+}
+
+// replaySplit starts t on a split as it was timed: from its start time,
+// with everything logged since applied in order. Pauses keep paused time
+// out of the elapsed time, and split events replay every extension,
+// restart, early end and soft-end switch. A split that is still paused
+// returns its open interruption.
+func replaySplit(store Store, t *timer.Timer, split *PomodoroSplit, session *Session) (*Interruption, error) {
+	interruptions, err := store.GetSplitInterruptions(split.ID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := store.GetSplitEvents(split.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type step struct {
+		at    time.Time
+		apply func()
+	}
+	var steps []step
+	var open *Interruption
+	for i := range interruptions {
+		interruption := &interruptions[i]
+		steps = append(steps, step{interruption.StartedAt, func() { t.PauseAt(interruption.StartedAt) }})
+		if interruption.EndedAt == nil {
+			open = interruption
+			continue
+		}
+		steps = append(steps, step{*interruption.EndedAt, func() { t.ResumeAt(*interruption.EndedAt) }})
+	}
+
+	// The session holds the latest soft-end setting; the split started
+	// with the opposite of its first switch
+	plan := splitPlan(split)
+	plan.SoftEnd = session.SoftEnd
+	softEndSet := false
+	for _, event := range events {
+		switch event.Kind {
+		case SplitEventSoftEndOn, SplitEventSoftEndOff:
+			if !softEndSet {
+				plan.SoftEnd = event.Kind == SplitEventSoftEndOff
+				softEndSet = true
+			}
+		}
+		steps = append(steps, step{event.At, func() { applySplitEvent(t, event) }})
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].at.Before(steps[j].at) })
+	t.StartAt(split.StartTime, plan)
+	for _, step := range steps {
+		step.apply()
+	}
+	if t.State() != timer.StatePaused {
+		open = nil
+	}
+	return open, nil
+}
+
+func applySplitEvent(t *timer.Timer, event SplitEvent) {
+	switch event.Kind {
+	case SplitEventExtended:
+		t.ExtendAt(event.At, time.Duration(event.Seconds)*time.Second)
+	case SplitEventRestarted:
+		t.RestartAt(event.At)
+	case SplitEventEnded:
+		t.EndPhaseAt(event.At)
+	case SplitEventSoftEndOn, SplitEventSoftEndOff:
+		t.TickAt(event.At)
+		t.SetSoftEnd(event.Kind == SplitEventSoftEndOn)
+	}
+}
+
+// recordSplitEvent logs a change to a running split for replaySplit.
+func recordSplitEvent(store Store, splitID int, kind string, at time.Time, seconds int) error {
+	_, err := store.CreateSplitEvent(SplitEvent{SplitID: splitID, Kind: kind, At: at, Seconds: seconds})
+	return err
+}
+
+// fixedClock always reads the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// splitTimer rebuilds the timer of an in-progress split as of now with
+// replaySplit, without touching the store. A paused split comes back
+// paused.
+func splitTimer(store Store, split *PomodoroSplit, now time.Time) (*timer.Timer, error) {
+	session, err := store.GetSession(split.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	t := timer.New(fixedClock(now))
+	if _, err := replaySplit(store, t, split, session); err != nil {
+		return nil, err
+	}
+	t.Tick()
+	return t, nil
+}
+
+// settleOrphans closes splits that nothing is timing any more, as the
+// TUI's recovery screen would: splits whose time ran out are completed and
+// the rest cancelled.
+func settleOrphans(store Store, now time.Time) error {
+	splits, err := store.GetInProgressSplits()
+	if err != nil {
+		return err
+	}
+	for i := range splits {
+		split := &splits[i]
+		t, err := splitTimer(store, split, now)
+		if err != nil {
+			return err
+		}
+		if splitLive(store, split, t) {
+			continue
+		}
+		// Another process may be picking it up right now
+		if claimed, err := claimSplit(store, split.ID); err != nil {
+			return err
+		} else if !claimed {
+			continue
+		}
+		status := "cancelled"
+		if t.State() == timer.StateFinished {
+			status = "completed"
+		}
+		if err := settleSplit(store, split, status, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *App) loadOrphans() {
+	splits, err := m.store.GetInProgressSplits()
+	if err != nil {
+		return
+	}
+
+	// Splits the daemon or another TUI is still timing are not orphans
+	orphans := splits[:0]
+	for _, split := range splits {
+		if !ownedElsewhere(m.store, split.ID) {
+			orphans = append(orphans, split)
+		}
+	}
+	if len(orphans) == 0 {
+		return
+	}
+	m.orphans = orphans
+	m.state = StateRecovery
+}
+
+func (m *App) currentOrphan() *PomodoroSplit {
+	if len(m.orphans) == 0 {
+		return nil
+	}
+	return &m.orphans[0]
+}
+
+func (m *App) nextOrphan() (tea.Model, tea.Cmd) {
+	m.orphans = m.orphans[1:]
+	if len(m.orphans) == 0 {
+		m.state = StateMainMenu
+	}
+	return m, nil
+}
+
+func (m *App) updateRecovery(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.notice = ""
+	split := m.currentOrphan()
+	if split == nil {
+		m.state = StateMainMenu
+		return m, nil
+	}
+
+	keys := currentConfig().Keymap
+	switch key := msg.String(); {
+	case keys.ResumeSplit.has(key):
+		return m.resumeOrphan(split)
+	case keys.CompleteSplit.has(key):
+		m.closeOrphan(split, "completed")
+		return m.nextOrphan()
+	case keys.CancelSplit.has(key):
+		m.closeOrphan(split, "cancelled")
+		return m.nextOrphan()
+	}
+	return m, nil
+}
+
+// resumeOrphan picks the timer up exactly where it would be had the app
+// never stopped, paused if it was paused. Splits whose rest phase has
+// already run out are simply completed.
+func (m *App) resumeOrphan(split *PomodoroSplit) (tea.Model, tea.Cmd) {
+	now := time.Now()
+	session, err := m.store.GetSession(split.SessionID)
+	if err != nil {
+		m.closeOrphan(split, "cancelled")
+		return m.nextOrphan()
+	}
+	// Another process may have picked it up since the list was loaded
+	if claimed, err := claimSplit(m.store, split.ID); err != nil || !claimed {
+		return m.nextOrphan()
+	}
+
+	// Only the interrupted split itself is resumed; the rest of its cycle
+	// cannot be reconstructed from the database.
+	m.session = session
+	m.currentSplit = split
+	m.cycle = cyclePlan{}
+	m.hooksFrom = now
+	m.interruption, _ = replaySplit(m.store, m.timer, split, session)
+	m.timer.Tick()
+	if finished, _ := m.drainTimerEvents(); finished {
+		m.session = nil
+		return m.nextOrphan()
+	}
+	m.orphans = nil
+
+	if m.interruption != nil {
+		m.state = StatePaused
+		return m, nil
+	}
+	m.state = StateTimer
+	return m, m.tickCmd()
+}
+
+// closeOrphan settles split unless another process has picked it up since
+// the list was loaded. What went wrong is left in m.notice.
+func (m *App) closeOrphan(split *PomodoroSplit, status string) {
+	claimed, err := claimSplit(m.store, split.ID)
+	if err == nil && !claimed {
+		m.notice = fmt.Sprintf("Split %d is being timed by another romodoro process", split.ID)
+		return
+	}
+	if err == nil {
+		err = settleSplit(m.store, split, status, time.Now())
+	}
+	if err != nil {
+		m.notice = fmt.Sprintf("Could not close split %d: %v", split.ID, err)
+	}
+}
+
+// settleSplit closes a split nobody is timing any more, crediting the time
+// its timer rebuilt by splitTimer says it got. Marking it completed also
+// credits what was left of the phase it was in, and the rest phase if it
+// was still focusing; phases that were skipped keep only the time they
+// had.
+func settleSplit(store Store, split *PomodoroSplit, status string, now time.Time) error {
+	t, err := splitTimer(store, split, now)
+	if err != nil {
+		return err
+	}
+	recordElapsed(split, t)
+
+	endTime := now
+	switch {
+	case t.State() == timer.StateFinished:
+		endTime = t.EndedAt()
+	case status == "completed":
+		left := int(t.Remaining().Seconds())
+		if t.CountingUp() || left < 0 {
+			left = 0
+		}
+		if t.Phase() == timer.Focus {
+			split.ActualFocusSeconds += left
+			split.ActualRestSeconds = int(t.SuggestedRest().Seconds())
+		} else {
+			split.ActualRestSeconds += left
+		}
+	}
+	split.EndTime = &endTime
+	split.Status = status
+
+	if err := store.UpdatePomodoroSplit(split); err != nil {
+		return err
+	}
+	return store.UpdateSessionTotals(split.SessionID)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"romodoro/timer"
+)
+
+// startTestSplit creates a session with one in-progress split.
+func startTestSplit(t *testing.T, store Store, split PomodoroSplit, softEnd bool) *PomodoroSplit {
+	t.Helper()
+	session, err := store.CreateSession("Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if softEnd {
+		if err := store.SetSessionSoftEnd(session.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	split.SessionID = session.ID
+	if split.Mode == "" {
+		split.Mode = SplitModeCountdown
+	}
+	created, err := store.CreatePomodoroSplit(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// testEvent is a split event at an offset from the start of the split.
+type testEvent struct {
+	kind    string
+	at      time.Duration
+	seconds int
+}
+
+// writeTestHistory records pauses and events for split, as offsets from its
+// start. A zero end leaves the pause open.
+func writeTestHistory(t *testing.T, store Store, split *PomodoroSplit, pauses [][2]time.Duration, events []testEvent) {
+	t.Helper()
+	start := split.StartTime
+	for _, pause := range pauses {
+		interruption := Interruption{SplitID: split.ID, Phase: "focus", StartedAt: start.Add(pause[0])}
+		created, err := store.CreateInterruption(interruption)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pause[1] != 0 {
+			ended := start.Add(pause[1])
+			created.EndedAt = &ended
+			if err := store.UpdateInterruption(created); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, event := range events {
+		if err := recordSplitEvent(store, split.ID, event.kind, start.Add(event.at), event.seconds); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSplitTimerReplaysChanges(t *testing.T) {
+	pomodoro := PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}
+	flowtime := PomodoroSplit{Mode: SplitModeFlowtime}
+
+	tests := []struct {
+		name    string
+		split   PomodoroSplit
+		softEnd bool
+		pauses  [][2]time.Duration
+		events  []testEvent
+		now     time.Duration
+
+		state     timer.State
+		phase     timer.Phase
+		remaining time.Duration
+	}{
+		{
+			name:  "untouched",
+			split: pomodoro, now: 27 * time.Minute,
+			state: timer.StateRunning, phase: timer.Rest, remaining: 3 * time.Minute,
+		},
+		{
+			name:   "focus skipped",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventEnded, 10 * time.Minute, 0}},
+			now:    12 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Rest, remaining: 3 * time.Minute,
+		},
+		{
+			name:   "rest skipped after focus ran out",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventEnded, 26 * time.Minute, 0}},
+			now:    27 * time.Minute,
+			state:  timer.StateFinished, phase: timer.Rest,
+		},
+		{
+			name:   "restarted",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventRestarted, 10 * time.Minute, 0}},
+			now:    20 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Focus, remaining: 15 * time.Minute,
+		},
+		{
+			name:   "extended",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventExtended, 20 * time.Minute, 300}},
+			now:    27 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Focus, remaining: 3 * time.Minute,
+		},
+		{
+			name:   "paused",
+			split:  pomodoro,
+			pauses: [][2]time.Duration{{5 * time.Minute, 15 * time.Minute}},
+			now:    20 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Focus, remaining: 15 * time.Minute,
+		},
+		{
+			name:   "still paused",
+			split:  pomodoro,
+			pauses: [][2]time.Duration{{5 * time.Minute, 0}},
+			now:    time.Hour,
+			state:  timer.StatePaused, phase: timer.Focus, remaining: 20 * time.Minute,
+		},
+		{
+			name:  "soft end in overtime",
+			split: pomodoro, softEnd: true,
+			now:   40 * time.Minute,
+			state: timer.StateRunning, phase: timer.Focus, remaining: -15 * time.Minute,
+		},
+		{
+			name:  "soft end acknowledged",
+			split: pomodoro, softEnd: true,
+			events: []testEvent{{SplitEventEnded, 30 * time.Minute, 0}},
+			now:    31 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Rest, remaining: 4 * time.Minute,
+		},
+		{
+			name:  "soft end switched on during the split",
+			split: pomodoro, softEnd: true,
+			events: []testEvent{{SplitEventSoftEndOn, 10 * time.Minute, 0}},
+			now:    40 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Focus, remaining: -15 * time.Minute,
+		},
+		{
+			name:  "soft end switched on after focus ended",
+			split: pomodoro, softEnd: true,
+			events: []testEvent{{SplitEventSoftEndOn, 26 * time.Minute, 0}},
+			now:    29 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Rest, remaining: time.Minute,
+		},
+		{
+			name:  "flowtime counting up",
+			split: flowtime,
+			now:   3 * time.Hour,
+			state: timer.StateRunning, phase: timer.Focus,
+		},
+		{
+			name:   "flowtime focus finished",
+			split:  flowtime,
+			events: []testEvent{{SplitEventEnded, 50 * time.Minute, 0}},
+			now:    52 * time.Minute,
+			state:  timer.StateRunning, phase: timer.Rest, remaining: 8 * time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store Store) {
+				split := startTestSplit(t, store, test.split, test.softEnd)
+				start := split.StartTime
+
+				writeTestHistory(t, store, split, test.pauses, test.events)
+
+				rebuilt, err := splitTimer(store, split, start.Add(test.now))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rebuilt.State() != test.state || rebuilt.Phase() != test.phase {
+					t.Fatalf("got %v %v, want %v %v", rebuilt.State(), rebuilt.Phase(), test.state, test.phase)
+				}
+				// A finished timer has nothing left to count down
+				if test.state != timer.StateFinished && !rebuilt.CountingUp() && rebuilt.Remaining() != test.remaining {
+					t.Errorf("remaining = %v, want %v", rebuilt.Remaining(), test.remaining)
+				}
+			})
+		})
+	}
+}
+
+func TestSettleSplitCreditsRebuiltTimer(t *testing.T) {
+	pomodoro := PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}
+
+	tests := []struct {
+		name    string
+		split   PomodoroSplit
+		softEnd bool
+		pauses  [][2]time.Duration
+		events  []testEvent
+		status  string
+		now     time.Duration
+
+		focus, rest, overtime int
+		end                   time.Duration
+	}{
+		{
+			name:   "cancelled less its pauses",
+			split:  pomodoro,
+			pauses: [][2]time.Duration{{5 * time.Minute, 10 * time.Minute}},
+			status: "cancelled", now: 20 * time.Minute,
+			focus: 900, end: 20 * time.Minute,
+		},
+		{
+			name:   "completed during focus",
+			split:  pomodoro,
+			status: "completed", now: 10 * time.Minute,
+			focus: 1500, rest: 300, end: 10 * time.Minute,
+		},
+		{
+			name:   "completed during rest",
+			split:  pomodoro,
+			status: "completed", now: 27 * time.Minute,
+			focus: 1500, rest: 300, end: 27 * time.Minute,
+		},
+		{
+			name:   "cancelled after skipping focus",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventEnded, 10 * time.Minute, 0}},
+			status: "cancelled", now: 12 * time.Minute,
+			focus: 600, rest: 120, end: 12 * time.Minute,
+		},
+		{
+			name:   "ran out by itself",
+			split:  pomodoro,
+			status: "completed", now: 2 * time.Hour,
+			focus: 1500, rest: 300, end: 30 * time.Minute,
+		},
+		{
+			name:   "rest skipped before the crash",
+			split:  pomodoro,
+			events: []testEvent{{SplitEventEnded, 26 * time.Minute, 0}},
+			status: "completed", now: 2 * time.Hour,
+			focus: 1500, rest: 60, end: 26 * time.Minute,
+		},
+		{
+			name:  "cancelled in overtime",
+			split: pomodoro, softEnd: true,
+			status: "cancelled", now: 40 * time.Minute,
+			focus: 2400, overtime: 900, end: 40 * time.Minute,
+		},
+		{
+			name:   "flowtime completed",
+			split:  PomodoroSplit{Mode: SplitModeFlowtime},
+			status: "completed", now: 50 * time.Minute,
+			focus: 3000, rest: 600, end: 50 * time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store Store) {
+				split := startTestSplit(t, store, test.split, test.softEnd)
+				writeTestHistory(t, store, split, test.pauses, test.events)
+				start := split.StartTime
+
+				if err := settleSplit(store, split, test.status, start.Add(test.now)); err != nil {
+					t.Fatal(err)
+				}
+				splits, err := store.GetSessionSplits(split.SessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := splits[0]
+				if got.Status != test.status {
+					t.Errorf("status %q, want %q", got.Status, test.status)
+				}
+				if got.ActualFocusSeconds != test.focus || got.ActualRestSeconds != test.rest || got.FocusOvertimeSeconds != test.overtime {
+					t.Errorf("focus %ds, rest %ds, overtime %ds; want %ds, %ds, %ds",
+						got.ActualFocusSeconds, got.ActualRestSeconds, got.FocusOvertimeSeconds, test.focus, test.rest, test.overtime)
+				}
+				if got.EndTime == nil || !got.EndTime.Equal(start.Add(test.end)) {
+					t.Errorf("ended at %v, want %v after the start", got.EndTime, test.end)
+				}
+
+				session, err := store.GetSession(split.SessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.TotalFocusSeconds != test.focus {
+					t.Errorf("session focus %ds, want %ds", session.TotalFocusSeconds, test.focus)
+				}
+			})
+		})
+	}
+}
+
+func TestSettleOrphans(t *testing.T) {
+	pomodoro := PomodoroSplit{FocusMinutes: 25, RestMinutes: 5}
+
+	// A process that has already exited stands in for a crashed owner
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip(err)
+	}
+	dead := exited.Process.Pid
+
+	tests := []struct {
+		name    string
+		split   PomodoroSplit
+		softEnd bool
+		pauses  [][2]time.Duration
+		owner   int
+		now     time.Duration
+		status  string
+	}{
+		{name: "countdown still running", split: pomodoro, now: 10 * time.Minute, status: "in_progress"},
+		{name: "countdown ran out", split: pomodoro, now: time.Hour, status: "completed"},
+		{name: "soft end", split: pomodoro, softEnd: true, now: 10 * time.Minute, status: "cancelled"},
+		{name: "flowtime", split: PomodoroSplit{Mode: SplitModeFlowtime}, now: 10 * time.Minute, status: "cancelled"},
+		{
+			name:  "paused",
+			split: pomodoro, pauses: [][2]time.Duration{{5 * time.Minute, 0}},
+			now: 10 * time.Minute, status: "cancelled",
+		},
+		{name: "owned by this process", split: pomodoro, softEnd: true, owner: os.Getpid(), now: 10 * time.Minute, status: "in_progress"},
+		{name: "owner exited", split: pomodoro, softEnd: true, owner: dead, now: 10 * time.Minute, status: "cancelled"},
+		{name: "owner exited mid countdown", split: pomodoro, owner: dead, now: 10 * time.Minute, status: "in_progress"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store Store) {
+				split := startTestSplit(t, store, test.split, test.softEnd)
+				writeTestHistory(t, store, split, test.pauses, nil)
+				if test.owner != 0 {
+					if _, err := store.SetSplitOwner(split.ID, 0, test.owner); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if err := settleOrphans(store, split.StartTime.Add(test.now)); err != nil {
+					t.Fatal(err)
+				}
+				splits, err := store.GetSessionSplits(split.SessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if splits[0].Status != test.status {
+					t.Errorf("status %q, want %q", splits[0].Status, test.status)
+				}
+			})
+		})
+	}
+}
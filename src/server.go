@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// apiError is the data of an "error" response.
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func cmdServe(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:7420", "`address` to listen on")
+	allowRemote := flags.Bool("allow-remote", false, "allow listening on an address other machines can reach")
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+
+	// The API has no authentication, so anyone who can reach it can read
+	// the history and control the timer
+	if !loopbackAddr(*addr) {
+		if !*allowRemote {
+			fmt.Fprintf(stderr, "romodoro serve: %s is not a loopback address; the API has no authentication, pass --allow-remote to serve it anyway\n", *addr)
+			return ExitUsage
+		}
+		fmt.Fprintf(stderr, "romodoro serve: warning: anyone who can reach %s can read your sessions and control the timer\n", *addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The control endpoints drive a daemon, so run one unless it is
+	// already running on its own
+	path := socketPath()
+	listener, err := listenSocket(path)
+	switch {
+	case errors.Is(err, errDaemonRunning):
+		fmt.Fprintf(stdout, "Using the daemon on %s\n", path)
+	case err != nil:
+		fmt.Fprintln(stderr, "romodoro serve:", err)
+		return ExitError
+	default:
+		defer os.Remove(path)
+		go func() {
+			if err := runDaemon(ctx, store, listener, stderr); err != nil {
+				fmt.Fprintln(stderr, "romodoro serve: daemon:", err)
+				stop()
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newAPIHandler(store, *allowRemote),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Event streams never finish on their own; end them on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(stdout, "Serving the API on http://%s/api/v1/ and a live timer on http://%s/\n", *addr, *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(stderr, "romodoro serve:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// apiServer answers the REST API. Reads go straight to the store; timer
+// control goes through the daemon, like the command line.
+type apiServer struct {
+	store Store
+}
+
+func newAPIHandler(store Store, allowRemote bool) http.Handler {
+	api := &apiServer{store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexPage)
+	})
+	mux.HandleFunc("GET /metrics", api.metrics)
+	mux.HandleFunc("GET /api/v1/status", api.status)
+	mux.HandleFunc("GET /api/v1/events", api.events)
+	mux.HandleFunc("GET /api/v1/stats", api.stats)
+	mux.HandleFunc("GET /api/v1/sessions", api.listSessions)
+	mux.HandleFunc("GET /api/v1/sessions/{id}", api.getSession)
+	mux.HandleFunc("DELETE /api/v1/sessions/{id}", api.deleteSession)
+	mux.HandleFunc("GET /api/v1/sessions/{id}/splits", api.listSplits)
+	mux.HandleFunc("POST /api/v1/timer/{action}", api.control)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint")
+	})
+	return localOnly(mux, allowRemote)
+}
+
+// loopbackAddr reports whether addr only listens on this machine. An empty
+// host listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// localOnly turns away requests a web page could make behind the user's
+// back: Host names other than localhost, which is how DNS rebinding gets
+// in, and writes from other origins. Unless remote access is allowed, the
+// Host must also be a loopback address.
+func localOnly(next http.Handler, allowRemote bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		host = strings.Trim(host, "[]")
+		if host != "localhost" {
+			ip := net.ParseIP(host)
+			if ip == nil {
+				writeAPIError(w, http.StatusForbidden, "requests must use localhost or an IP address")
+				return
+			}
+			if !allowRemote && !ip.IsLoopback() {
+				writeAPIError(w, http.StatusForbidden, "requests must use localhost or a loopback address")
+				return
+			}
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && r.Method != http.MethodGet {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeAPIError(w, http.StatusForbidden, "cross-origin requests may only read")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeAPI(w http.ResponseWriter, status int, recordType string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(envelope{SchemaVersion: outputSchemaVersion, Type: recordType, Data: data})
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPI(w, status, "error", apiError{Status: status, Message: message})
+}
+
+// writeDaemonError maps errors from the daemon onto HTTP statuses the
+// same way exitCode maps them onto exit codes.
+func writeDaemonError(w http.ResponseWriter, err error) {
+	var rpcErr *rpcError
+	if !errors.As(err, &rpcErr) {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch rpcErr.Code {
+	case ExitRunning, ExitNotRunning:
+		writeAPIError(w, http.StatusConflict, rpcErr.Message)
+	case rpcInvalidParams, rpcParseError:
+		writeAPIError(w, http.StatusBadRequest, rpcErr.Message)
+	default:
+		writeAPIError(w, http.StatusInternalServerError, rpcErr.Message)
+	}
+}
+
+func (api *apiServer) status(w http.ResponseWriter, r *http.Request) {
+	status, err := currentStatus(api.store)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPI(w, http.StatusOK, "status", status)
+}
+
+func (api *apiServer) stats(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 0 {
+			writeAPIError(w, http.StatusBadRequest, "days must be a number of days, or 0 for all time")
+			return
+		}
+	}
+
+	now := time.Now()
+	stats, err := collectStats(api.store, statsSince(now, days), now)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPI(w, http.StatusOK, "stats", stats)
+}
+
+func (api *apiServer) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := api.store.GetAllSessions()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sessions == nil {
+		sessions = []Session{}
+	}
+	writeAPI(w, http.StatusOK, "sessions", sessions)
+}
+
+// session looks up the session named in the path, answering the request
+// itself when there is none.
+func (api *apiServer) session(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid session ID %q", r.PathValue("id")))
+		return nil, false
+	}
+	session, err := api.store.GetSession(id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("session %d not found", id))
+		return nil, false
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return session, true
+}
+
+func (api *apiServer) getSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := api.session(w, r)
+	if !ok {
+		return
+	}
+	splits, err := api.store.GetSessionSplits(session.ID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if splits == nil {
+		splits = []PomodoroSplit{}
+	}
+	writeAPI(w, http.StatusOK, "session_detail", sessionDetail{Session: *session, Splits: splits})
+}
+
+func (api *apiServer) listSplits(w http.ResponseWriter, r *http.Request) {
+	session, ok := api.session(w, r)
+	if !ok {
+		return
+	}
+	splits, err := api.store.GetSessionSplits(session.ID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if splits == nil {
+		splits = []PomodoroSplit{}
+	}
+	writeAPI(w, http.StatusOK, "splits", splits)
+}
+
+func (api *apiServer) deleteSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := api.session(w, r)
+	if !ok {
+		return
+	}
+	if err := api.store.DeleteSession(session.ID); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// control forwards start, pause, resume, skip and stop to the daemon and
+// answers with the status after it.
+func (api *apiServer) control(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	var params any
+	switch action {
+	case "start":
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(body) > 0 {
+			if !json.Valid(body) {
+				writeAPIError(w, http.StatusBadRequest, "the body is not valid JSON")
+				return
+			}
+			params = json.RawMessage(body)
+		}
+	case "pause", "resume", "skip", "stop":
+	default:
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown timer action %q", action))
+		return
+	}
+
+	status, ok, err := callDaemon(action, params)
+	if !ok {
+		writeAPIError(w, http.StatusServiceUnavailable, "no daemon running")
+		return
+	}
+	if err != nil {
+		writeDaemonError(w, err)
+		return
+	}
+	writeAPI(w, http.StatusOK, "status", status)
+}
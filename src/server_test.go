@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:7777", true},
+		{"127.1.2.3:7777", true},
+		{"localhost:7777", true},
+		{"[::1]:7777", true},
+		{":7777", false}, // every interface
+		{"0.0.0.0:7777", false},
+		{"[::]:7777", false},
+		{"192.168.1.10:7777", false},
+		{"example.com:7777", false},
+		{"127.0.0.1", false}, // no port
+	}
+	for _, test := range tests {
+		if got := loopbackAddr(test.addr); got != test.want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		allowRemote bool
+		want        int
+	}{
+		{name: "localhost", host: "localhost:7777", want: http.StatusOK},
+		{name: "localhost without a port", host: "localhost", want: http.StatusOK},
+		{name: "IPv4 loopback", host: "127.0.0.1:7777", want: http.StatusOK},
+		{name: "IPv6 loopback", host: "[::1]:7777", want: http.StatusOK},
+		{name: "IPv6 loopback without a port", host: "[::1]", want: http.StatusOK},
+		{name: "rebound name", host: "attacker.example:7777", want: http.StatusForbidden},
+		{name: "rebound name with remote access", host: "attacker.example:7777", allowRemote: true, want: http.StatusForbidden},
+		{name: "LAN address", host: "192.168.1.10:7777", want: http.StatusForbidden},
+		{name: "LAN address with remote access", host: "192.168.1.10:7777", allowRemote: true, want: http.StatusOK},
+		{name: "cross-origin read", host: "localhost:7777", origin: "http://attacker.example", want: http.StatusOK},
+		{name: "cross-origin write", method: http.MethodPost, host: "localhost:7777", origin: "http://attacker.example", want: http.StatusForbidden},
+		{name: "cross-port write", method: http.MethodPost, host: "localhost:7777", origin: "http://localhost:8080", want: http.StatusForbidden},
+		{name: "same-origin write", method: http.MethodPost, host: "localhost:7777", origin: "http://localhost:7777", want: http.StatusOK},
+		{name: "write without an origin", method: http.MethodPost, host: "localhost:7777", want: http.StatusOK},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/api/status", nil)
+			r.Host = test.host
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			w := httptest.NewRecorder()
+			localOnly(ok, test.allowRemote).ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("status %d, want %d: %s", w.Code, test.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stats sums up the sessions started in a period.
+type Stats struct {
+	From                 *time.Time `json:"from"` // nil for all time
+	To                   time.Time  `json:"to"`
+	Sessions             int        `json:"sessions"`
+	SplitsCompleted      int        `json:"splits_completed"`
+	SplitsCancelled      int        `json:"splits_cancelled"`
+	FocusSeconds         int        `json:"focus_seconds"`
+	RestSeconds          int        `json:"rest_seconds"`
+	FocusOvertimeSeconds int        `json:"focus_overtime_seconds"`
+	RestOvertimeSeconds  int        `json:"rest_overtime_seconds"`
+	Interruptions        int        `json:"interruptions"`
+}
+
+func collectStats(store Store, from *time.Time, to time.Time) (Stats, error) {
+	stats := Stats{From: from, To: to}
+
+	sessions, err := store.GetAllSessions()
+	if err != nil {
+		return stats, err
+	}
+	splits, err := splitsBySession(store)
+	if err != nil {
+		return stats, err
+	}
+	for _, session := range sessions {
+		if (from != nil && session.StartTime.Before(*from)) || !session.StartTime.Before(to) {
+			continue
+		}
+
+		stats.Sessions++
+		stats.Interruptions += session.InterruptionCount
+		for _, split := range splits[session.ID] {
+			switch split.Status {
+			case "completed":
+				stats.SplitsCompleted++
+			case "cancelled":
+				stats.SplitsCancelled++
+			}
+			stats.FocusSeconds += split.ActualFocusSeconds
+			stats.RestSeconds += split.ActualRestSeconds
+			stats.FocusOvertimeSeconds += split.FocusOvertimeSeconds
+			stats.RestOvertimeSeconds += split.RestOvertimeSeconds
+		}
+	}
+	return stats, nil
+}
+
+// splitsBySession loads every split in one query, keyed by session ID.
+func splitsBySession(store Store) (map[int][]PomodoroSplit, error) {
+	splits, err := store.GetAllSplits()
+	if err != nil {
+		return nil, err
+	}
+	bySession := make(map[int][]PomodoroSplit)
+	for _, split := range splits {
+		bySession[split.SessionID] = append(bySession[split.SessionID], split)
+	}
+	return bySession, nil
+}
+
+// statsSince is the start of the day days-1 days before now, or nil for
+// all time when days is 0.
+func statsSince(now time.Time, days int) *time.Time {
+	if days <= 0 {
+		return nil
+	}
+	year, month, day := now.Date()
+	start := time.Date(year, month, day-days+1, 0, 0, 0, 0, now.Location())
+	return &start
+}
+
+func cmdStats(store Store, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	days := flags.Int("days", 7, "number of `days` to cover; 0 for all time")
+	output := addOutputFlags(flags)
+	if code, ok := parseFlags(flags, args, stderr); !ok {
+		return code
+	}
+	format, err := output.format()
+	switch {
+	case err != nil:
+	case *days < 0:
+		err = fmt.Errorf("--days must not be negative")
+	case flags.NArg() > 0:
+		err = fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro stats:", err)
+		return ExitUsage
+	}
+
+	now := time.Now()
+	from := statsSince(now, *days)
+	stats, err := collectStats(store, from, now)
+	if err != nil {
+		fmt.Fprintln(stderr, "romodoro stats:", err)
+		return ExitError
+	}
+
+	if format != OutputTable {
+		if err := writeOne(stdout, format, "stats", stats); err != nil {
+			fmt.Fprintln(stderr, "romodoro stats:", err)
+			return ExitError
+		}
+		return ExitOK
+	}
+
+	period := "All time"
+	if from != nil {
+		period = fmt.Sprintf("Since %s", from.Format("2006-01-02"))
+	}
+	fmt.Fprintf(stdout, "%s\n\n", period)
+	fmt.Fprintf(stdout, "%-18s %d\n", "Sessions", stats.Sessions)
+	fmt.Fprintf(stdout, "%-18s %d\n", "Splits completed", stats.SplitsCompleted)
+	fmt.Fprintf(stdout, "%-18s %d\n", "Splits cancelled", stats.SplitsCancelled)
+	fmt.Fprintf(stdout, "%-18s %s\n", "Focus", formatClock(stats.FocusSeconds))
+	fmt.Fprintf(stdout, "%-18s %s\n", "Rest", formatClock(stats.RestSeconds))
+	fmt.Fprintf(stdout, "%-18s %s focus, %s rest\n", "Overtime",
+		formatClock(stats.FocusOvertimeSeconds), formatClock(stats.RestOvertimeSeconds))
+	fmt.Fprintf(stdout, "%-18s %d\n", "Interruptions", stats.Interruptions)
+	return ExitOK
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// statusFormats are the ready-made templates for `status --format`.
+var statusFormats = map[string]string{
+	"tmux": `{{if .Running}}{{if .Paused}}#[fg=yellow]{{else if eq .Phase "rest"}}#[fg=green]{{else}}#[fg=red]{{end}}` +
+		`{{.Text}}#[default]{{end}}`,
+	"waybar": `{"text": {{json .Text}}, "tooltip": {{json .Tooltip}}, "class": {{json .Class}}, "alt": {{json .Class}}}`,
+	"short":  `{{.Text}}`,
+}
+
+// statusView is what status templates see: the status itself plus a few
+// fields that are awkward to build in a template.
+type statusView struct {
+	TimerStatus
+	Running bool   // a split is running or paused
+	Paused  bool   // the split is paused
+	Icon    string // 🍅, ☕ or ⏸
+	Clock   string // remaining time, or elapsed time when counting up
+	Text    string // icon and clock; empty when idle
+	Class   string // idle, focus, rest or paused
+	Tooltip string
+}
+
+func newStatusView(status TimerStatus) statusView {
+	view := statusView{TimerStatus: status, Class: "idle", Tooltip: "No split running"}
+	if status.State == "idle" {
+		return view
+	}
+
+	view.Running = true
+	view.Paused = status.State == "paused"
+	view.Class = status.Phase
+	view.Icon = "🍅"
+	if status.Phase == "rest" {
+		view.Icon = "☕"
+	}
+	if view.Paused {
+		view.Class = "paused"
+		view.Icon = "⏸"
+	}
+
+	view.Clock = formatClock(status.RemainingSeconds)
+	if status.RemainingSeconds < 0 {
+		// A soft-end phase in overtime
+		view.Clock = "+" + formatClock(-status.RemainingSeconds)
+	}
+	if status.CountingUp {
+		view.Clock = formatClock(status.ElapsedSeconds)
+	}
+	view.Text = view.Icon + " " + view.Clock
+	view.Tooltip = status.Phase + " in " + status.SessionName
+	if status.SplitNumber > 0 {
+		view.Tooltip += ", split " + strconv.Itoa(status.SplitNumber)
+	}
+	return view
+}
+
+// parseStatusFormat accepts the name of a ready-made format or a template.
+func parseStatusFormat(format string) (*template.Template, error) {
+	if named, ok := statusFormats[format]; ok {
+		format = named
+	}
+	return template.New("status").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			encoded, err := json.Marshal(v)
+			return string(encoded), err
+		},
+		"clock": formatClock,
+	}).Parse(format)
+}
+
+func renderStatus(w io.Writer, tmpl *template.Template, status TimerStatus) error {
+	if err := tmpl.Execute(w, newStatusView(status)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrPresetExists = errors.New("a preset with that name already exists")
+)
+
+// builtinPresets mirror the rows seeded by migration 3 for backends that
+// have no migrations.
+var builtinPresets = []Preset{
+	{Name: "Pomodoro", FocusMinutes: 25, RestMinutes: 5, LongBreakMinutes: 15, CycleLength: 4, BuiltIn: true},
+	{Name: "Deep Work", FocusMinutes: 50, RestMinutes: 10, LongBreakMinutes: 10, CycleLength: 1, BuiltIn: true},
+	{Name: "Ultradian", FocusMinutes: 90, RestMinutes: 20, LongBreakMinutes: 20, CycleLength: 1, BuiltIn: true},
+}
+
+// Store is the persistence layer behind the app. Every backend must behave
+// the same way: sessions are returned newest first, and lookups of unknown
+// IDs return ErrNotFound.
+type Store interface {
+	CreateSession(name string) (*Session, error)
+	GetSession(sessionID int) (*Session, error)
+	GetLastSession() (*Session, error)
+	GetAllSessions() ([]Session, error)
+	DeleteSession(sessionID int) error
+	CloseSession(sessionID int) error
+	UpdateSessionTotals(sessionID int) error
+	SetSessionAutoContinue(sessionID int, enabled bool, graceSeconds int) error
+	SetSessionSoftEnd(sessionID int, enabled bool) error
+
+	CreatePomodoroSplit(split PomodoroSplit) (*PomodoroSplit, error)
+	UpdatePomodoroSplit(split *PomodoroSplit) error
+	GetSessionSplits(sessionID int) ([]PomodoroSplit, error)
+	GetInProgressSplits() ([]PomodoroSplit, error)
+	GetAllSplits() ([]PomodoroSplit, error)
+	GetSplitOwner(splitID int) (int, error)
+	SetSplitOwner(splitID, from, to int) (bool, error)
+
+	CreateInterruption(interruption Interruption) (*Interruption, error)
+	UpdateInterruption(interruption *Interruption) error
+	GetSplitInterruptions(splitID int) ([]Interruption, error)
+
+	CreateSplitEvent(event SplitEvent) (*SplitEvent, error)
+	GetSplitEvents(splitID int) ([]SplitEvent, error)
+
+	QueueWebhook(delivery WebhookDelivery) (*WebhookDelivery, error)
+	ClaimWebhooks(now, until time.Time, limit int) ([]WebhookDelivery, error)
+	UpdateWebhook(delivery *WebhookDelivery) error
+	DeleteWebhook(deliveryID int) error
+
+	ListPresets() ([]Preset, error)
+	CreatePreset(preset Preset) (*Preset, error)
+	SetPresetAutoContinue(presetID int, enabled bool, graceSeconds int) error
+	DeletePreset(presetID int) error
+
+	Close() error
+}
+
+// wallNow is the current time without its monotonic reading. Start times
+// handed out by the stores are later subtracted from the timer's clock,
+// and a monotonic difference would leave out time the machine spent
+// suspended.
+func wallNow() time.Time {
+	return time.Now().Round(0)
+}
+
+const (
+	BackendSQLite = "sqlite"
+	BackendJSON   = "json"
+	BackendMemory = "memory"
+)
+
+func OpenStore(backend, path string) (Store, error) {
+	switch backend {
+	case BackendSQLite, "":
+		return NewSQLiteStore(path)
+	case BackendJSON:
+		return NewJSONStore(path)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore runs test against every backend.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("json", func(t *testing.T) {
+		store, err := NewJSONStore(filepath.Join(t.TempDir(), "sessions.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
+func TestStoreSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, err := store.GetLastSession(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("last session of an empty store: %v, want ErrNotFound", err)
+		}
+
+		first, err := store.CreateSession("First")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := store.CreateSession("Second")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		all, err := store.GetAllSessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].ID != second.ID || all[1].ID != first.ID {
+			t.Errorf("sessions %v, want newest first", all)
+		}
+		if last, err := store.GetLastSession(); err != nil || last.ID != second.ID {
+			t.Errorf("last session = %v, %v; want %d", last, err, second.ID)
+		}
+		if _, err := store.GetSession(first.ID + second.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("unknown session: %v, want ErrNotFound", err)
+		}
+
+		if err := store.SetSessionSoftEnd(first.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetSessionAutoContinue(first.ID, true, 10); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.GetSession(first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "First" || !got.SoftEnd || !got.AutoContinue || got.AutoContinueGraceSeconds != 10 {
+			t.Errorf("session = %+v", got)
+		}
+
+		if err := store.DeleteSession(first.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.GetSession(first.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleted session: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreSplits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		session, err := store.CreateSession("Splits")
+		if err != nil {
+			t.Fatal(err)
+		}
+		create := func() *PomodoroSplit {
+			t.Helper()
+			split, err := store.CreatePomodoroSplit(PomodoroSplit{SessionID: session.ID, FocusMinutes: 25, RestMinutes: 5, Mode: SplitModeCountdown})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return split
+		}
+		done, running := create(), create()
+		if done.Status != "in_progress" || done.StartTime.IsZero() {
+			t.Errorf("new split = %+v, want in progress from now", done)
+		}
+
+		end := done.StartTime.Add(30 * time.Minute)
+		done.EndTime = &end
+		done.Status = "completed"
+		done.ActualFocusSeconds = 1500
+		done.ActualRestSeconds = 300
+		done.FocusSkipped = true
+		done.Restarts = 2
+		if err := store.UpdatePomodoroSplit(done); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateSessionTotals(session.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		splits, err := store.GetSessionSplits(session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(splits) != 2 || splits[0].ID != done.ID || splits[1].ID != running.ID {
+			t.Fatalf("session splits %v, want oldest first", splits)
+		}
+		got := splits[0]
+		if got.Status != "completed" || got.EndTime == nil || !got.EndTime.Equal(end) ||
+			got.ActualFocusSeconds != 1500 || !got.FocusSkipped || got.Restarts != 2 {
+			t.Errorf("updated split = %+v", got)
+		}
+		if inProgress, err := store.GetInProgressSplits(); err != nil || len(inProgress) != 1 || inProgress[0].ID != running.ID {
+			t.Errorf("in progress = %v, %v; want split %d", inProgress, err, running.ID)
+		}
+		if all, err := store.GetAllSplits(); err != nil || len(all) != 2 {
+			t.Errorf("all splits = %v, %v; want 2", all, err)
+		}
+		if totals, err := store.GetSession(session.ID); err != nil || totals.TotalFocusSeconds != 1500 || totals.TotalRestSeconds != 300 {
+			t.Errorf("session totals = %+v, %v; want 1500s focus, 300s rest", totals, err)
+		}
+
+		if err := store.DeleteSession(session.ID); err != nil {
+			t.Fatal(err)
+		}
+		if all, err := store.GetAllSplits(); err != nil || len(all) != 0 {
+			t.Errorf("splits after deleting their session = %v, %v", all, err)
+		}
+	})
+}
+
+func TestStoreSplitOwner(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		split := startTestSplit(t, store, PomodoroSplit{FocusMinutes: 25}, false)
+
+		steps := []struct {
+			from, to int
+			changed  bool
+			owner    int
+		}{
+			{0, 100, true, 100},
+			{0, 200, false, 100}, // someone else got there first
+			{100, 200, true, 200},
+			{200, 0, true, 0},
+		}
+		for _, step := range steps {
+			changed, err := store.SetSplitOwner(split.ID, step.from, step.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			owner, err := store.GetSplitOwner(split.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != step.changed || owner != step.owner {
+				t.Errorf("%d → %d: changed %v, owner %d; want %v, %d", step.from, step.to, changed, owner, step.changed, step.owner)
+			}
+		}
+
+		if _, err := store.GetSplitOwner(split.ID + 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("owner of an unknown split: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreInterruptionsAndEvents(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		split := startTestSplit(t, store, PomodoroSplit{FocusMinutes: 25}, false)
+		start := split.StartTime
+
+		// Created out of order, returned oldest first
+		later, err := store.CreateInterruption(Interruption{SplitID: split.ID, Phase: "focus", StartedAt: start.Add(10 * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.CreateInterruption(Interruption{SplitID: split.ID, Phase: "focus", StartedAt: start.Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		ended := start.Add(12 * time.Minute)
+		later.EndedAt = &ended
+		later.DurationSeconds = 120
+		later.Kind = InterruptionExternal
+		later.Reason = "phone"
+		if err := store.UpdateInterruption(later); err != nil {
+			t.Fatal(err)
+		}
+
+		interruptions, err := store.GetSplitInterruptions(split.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(interruptions) != 2 || interruptions[1].ID != later.ID {
+			t.Fatalf("interruptions %v, want oldest first", interruptions)
+		}
+		got := interruptions[1]
+		if got.EndedAt == nil || !got.EndedAt.Equal(ended) || got.Kind != InterruptionExternal || got.Reason != "phone" {
+			t.Errorf("updated interruption = %+v", got)
+		}
+		if session, err := store.GetSession(split.SessionID); err != nil || session.InterruptionCount != 2 {
+			t.Errorf("session = %+v, %v; want 2 interruptions", session, err)
+		}
+
+		if err := recordSplitEvent(store, split.ID, SplitEventExtended, start.Add(20*time.Minute), 300); err != nil {
+			t.Fatal(err)
+		}
+		if err := recordSplitEvent(store, split.ID, SplitEventRestarted, start.Add(5*time.Minute), 0); err != nil {
+			t.Fatal(err)
+		}
+		events, err := store.GetSplitEvents(split.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Kind != SplitEventRestarted || events[1].Kind != SplitEventExtended || events[1].Seconds != 300 {
+			t.Errorf("events %+v, want the restart then the extension", events)
+		}
+	})
+}
+
+func TestStorePresets(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		presets, err := store.ListPresets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(presets) != len(builtinPresets) {
+			t.Fatalf("%d presets in a new store, want the %d built-in ones", len(presets), len(builtinPresets))
+		}
+
+		custom, err := store.CreatePreset(Preset{Name: "Short", FocusMinutes: 15, RestMinutes: 3, CycleLength: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if custom.BuiltIn {
+			t.Error("a created preset is marked built-in")
+		}
+		if _, err := store.CreatePreset(Preset{Name: "Short", FocusMinutes: 20}); !errors.Is(err, ErrPresetExists) {
+			t.Errorf("duplicate name: %v, want ErrPresetExists", err)
+		}
+		if err := store.SetPresetAutoContinue(custom.ID, true, 5); err != nil {
+			t.Fatal(err)
+		}
+
+		// Built-in presets cannot be deleted
+		if err := store.DeletePreset(presets[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		presets, err = store.ListPresets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(presets) != len(builtinPresets)+1 {
+			t.Fatalf("%d presets, want %d", len(presets), len(builtinPresets)+1)
+		}
+		if last := presets[len(presets)-1]; last.ID != custom.ID || !last.AutoContinue || last.AutoContinueGraceSeconds != 5 {
+			t.Errorf("custom preset = %+v", last)
+		}
+
+		if err := store.DeletePreset(custom.ID); err != nil {
+			t.Fatal(err)
+		}
+		if presets, err := store.ListPresets(); err != nil || len(presets) != len(builtinPresets) {
+			t.Errorf("%d presets after deleting the custom one, %v", len(presets), err)
+		}
+	})
+}
+
+// Two JSONStores on one file stand in for two processes.
+func TestJSONStoreSharesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	open := func() *JSONStore {
+		t.Helper()
+		store, err := NewJSONStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	tui, daemon := open(), open()
+
+	first, err := tui.CreateSession("From the TUI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := daemon.CreateSession("From the daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Errorf("both processes handed out session %d", first.ID)
+	}
+
+	// Each sees the other's change without reopening the file
+	for name, store := range map[string]*JSONStore{"tui": tui, "daemon": daemon} {
+		sessions, err := store.GetAllSessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 2 {
+			t.Errorf("%s sees %d sessions, want 2", name, len(sessions))
+		}
+	}
+
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Errorf("lock file: %v", err)
+	}
+}
+
+func TestJSONStoreLocksAcrossStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	holder, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	other, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	// Hold the file lock as an operation in another process would
+	if err := holder.reload(); err != nil {
+		t.Fatal(err)
+	}
+	created := make(chan error, 1)
+	go func() {
+		_, err := other.CreateSession("Waiting")
+		created <- err
+	}()
+
+	select {
+	case err := <-created:
+		t.Fatalf("created a session while the file was locked: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	holder.releaseLock()
+	if err := <-created; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if store, err := NewJSONStore(path); err == nil {
+		store.Close()
+		t.Error("opened a corrupt file")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+//go:embed index.html
+var indexPage []byte
+
+// Events on the stream. Every one carries the full status, so a client
+// can render from any of them.
+const (
+	StreamStatus  = "status" // sent once, on connecting
+	StreamTick    = "tick"
+	StreamPhase   = "phase" // a phase or split started or ended
+	StreamPaused  = "paused"
+	StreamResumed = "resumed"
+)
+
+// streamEvent names the change from prev to next.
+func streamEvent(prev, next TimerStatus) string {
+	switch {
+	case prev.SplitID != next.SplitID || prev.Phase != next.Phase:
+		return StreamPhase
+	case prev.State == "running" && next.State == "paused":
+		return StreamPaused
+	case prev.State == "paused" && next.State == "running":
+		return StreamResumed
+	}
+	return StreamTick
+}
+
+// events streams the timer as Server-Sent Events until the client goes
+// away or the daemon stops. While the daemon is idle the stream follows
+// the split the TUI is timing, if any, from the database; the daemon's
+// updates every second drive the polling.
+func (api *apiServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	client, err := dialDaemon(socketPath())
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "no daemon running")
+		return
+	}
+	defer client.Close()
+	status, updates, err := client.Subscribe()
+	if err != nil {
+		writeDaemonError(w, err)
+		return
+	}
+	status = api.orStoredStatus(status)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Browsers reconnect on their own; ask them not to wait long
+	fmt.Fprint(w, "retry: 2000\n\n")
+	if err := writeStreamEvent(w, StreamStatus, status); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			update = api.orStoredStatus(update)
+			if err := writeStreamEvent(w, streamEvent(status, update), update); err != nil {
+				return
+			}
+			flusher.Flush()
+			status = update
+		}
+	}
+}
+
+// orStoredStatus swaps an idle daemon status for the split in the
+// database, as currentStatus does.
+func (api *apiServer) orStoredStatus(status TimerStatus) TimerStatus {
+	if status.State != "idle" {
+		return status
+	}
+	if stored, err := storedStatus(api.store); err == nil {
+		return stored
+	}
+	return status
+}
+
+func writeStreamEvent(w http.ResponseWriter, event string, status TimerStatus) error {
+	data, err := json.Marshal(envelope{SchemaVersion: outputSchemaVersion, Type: "status", Data: status})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
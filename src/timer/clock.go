@@ -0,0 +1,99 @@
+package timer
+
+import "time"
+
+// Clock is the source of time for a Timer. Tests and simulations can supply
+// their own implementation.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the wall clock. The monotonic reading is stripped:
+// it stops while the machine is suspended, and a phase that spans a
+// suspend must still be credited the time that passed.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now().Round(0)
+}
+
+// phaseClock measures one timer phase against the wall clock instead of
+// counting ticks, so late ticks, a stopped process or a suspended laptop
+// never skew the recorded time. Pauses are accumulated and pushed onto the
+// deadline.
+type phaseClock struct {
+	start       time.Time
+	duration    time.Duration
+	pausedAt    time.Time
+	pausedTotal time.Duration
+
+	// unbounded clocks count up forever and never expire.
+	unbounded bool
+
+	// overrun clocks keep counting past their deadline instead of
+	// stopping at it.
+	overrun bool
+}
+
+func newPhaseClock(start time.Time, duration time.Duration) phaseClock {
+	return phaseClock{start: start, duration: duration}
+}
+
+func newStopwatch(start time.Time) phaseClock {
+	return phaseClock{start: start, unbounded: true}
+}
+
+func (c *phaseClock) paused() bool {
+	return !c.pausedAt.IsZero()
+}
+
+func (c *phaseClock) pause(now time.Time) {
+	if !c.paused() {
+		c.pausedAt = now
+	}
+}
+
+func (c *phaseClock) resume(now time.Time) {
+	if c.paused() {
+		c.pausedTotal += now.Sub(c.pausedAt)
+		c.pausedAt = time.Time{}
+	}
+}
+
+// deadline is the moment the phase ends if it is not paused again.
+func (c *phaseClock) deadline() time.Time {
+	return c.start.Add(c.duration + c.pausedTotal)
+}
+
+func (c *phaseClock) elapsed(now time.Time) time.Duration {
+	if c.paused() {
+		now = c.pausedAt
+	}
+	elapsed := now.Sub(c.start) - c.pausedTotal
+	if elapsed < 0 {
+		return 0
+	}
+	if !c.unbounded && !c.overrun && elapsed > c.duration {
+		return c.duration
+	}
+	return elapsed
+}
+
+func (c *phaseClock) remaining(now time.Time) time.Duration {
+	if c.unbounded {
+		return 0
+	}
+	return c.duration - c.elapsed(now)
+}
+
+// overtime is how far an overrun clock has gone past its deadline.
+func (c *phaseClock) overtime(now time.Time) time.Duration {
+	if c.unbounded {
+		return 0
+	}
+	return max(c.elapsed(now)-c.duration, 0)
+}
+
+func (c *phaseClock) expired(now time.Time) bool {
+	return !c.unbounded && !c.paused() && !now.Before(c.deadline())
+}
@@ -0,0 +1,559 @@
+// Package timer implements the Pomodoro split state machine independently
+// of any user interface. A split is a focus phase followed by a rest phase,
+// each either counting down from a planned length or, for count-up
+// (flowtime) focus, running until EndPhase is called:
+//
+//	Idle → Running ⇄ Paused → Finished
+//	         └──────┴──────→ Cancelled
+//
+// The timer never schedules anything itself; the owner calls Tick as often
+// as it likes and the timer works out what happened from its Clock.
+package timer
+
+import (
+	"sync"
+	"time"
+)
+
+type Phase int
+
+const (
+	Focus Phase = iota
+	Rest
+)
+
+func (p Phase) String() string {
+	if p == Rest {
+		return "rest"
+	}
+	return "focus"
+}
+
+type State int
+
+const (
+	StateIdle State = iota
+	StateRunning
+	StatePaused
+	StateFinished
+	StateCancelled
+)
+
+func (s State) String() string {
+	switch s {
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateFinished:
+		return "finished"
+	case StateCancelled:
+		return "cancelled"
+	}
+	return "idle"
+}
+
+type EventType int
+
+const (
+	PhaseStarted EventType = iota
+	PhaseEnded
+	Paused
+	Resumed
+	Cancelled
+	PhaseExtended
+	PhaseRestarted
+	PhaseOvertime
+)
+
+func (t EventType) String() string {
+	switch t {
+	case PhaseStarted:
+		return "phase_started"
+	case PhaseEnded:
+		return "phase_ended"
+	case Paused:
+		return "paused"
+	case Resumed:
+		return "resumed"
+	case Cancelled:
+		return "cancelled"
+	case PhaseExtended:
+		return "phase_extended"
+	case PhaseRestarted:
+		return "phase_restarted"
+	case PhaseOvertime:
+		return "phase_overtime"
+	}
+	return "unknown"
+}
+
+// Event reports a transition. At is when the transition happened according
+// to the timer, which for phases that ended while nobody was ticking is the
+// original deadline rather than the time Tick noticed.
+type Event struct {
+	Type  EventType
+	Phase Phase
+	At    time.Time
+}
+
+const eventBuffer = 64
+
+// DefaultRestDivisor gives the classic flowtime suggestion of one minute of
+// rest for every five minutes of focus.
+const DefaultRestDivisor = 5
+
+// Plan describes one split.
+type Plan struct {
+	Focus time.Duration
+	Rest  time.Duration
+
+	// CountUp makes the focus phase open-ended. Rest is then ignored and
+	// derived from the focus time divided by RestDivisor.
+	CountUp     bool
+	RestDivisor int
+
+	// SoftEnd keeps a phase running into overtime when its time is up. A
+	// PhaseOvertime event fires at the deadline and the phase only ends
+	// when EndPhase is called.
+	SoftEnd bool
+}
+
+type Timer struct {
+	mu     sync.Mutex
+	clock  Clock
+	events chan Event
+
+	state   State
+	phase   Phase
+	plan    Plan
+	current phaseClock
+
+	focusElapsed time.Duration
+	restElapsed  time.Duration
+
+	// Overtime is part of the elapsed time above, broken out separately.
+	focusOvertime   time.Duration
+	restOvertime    time.Duration
+	overtimeAlerted bool
+
+	// carried is time spent in attempts of the current phase that were
+	// restarted. It still counts as time actually spent.
+	carried time.Duration
+
+	ended time.Time
+}
+
+func New(clock Clock) *Timer {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Timer{
+		clock:  clock,
+		events: make(chan Event, eventBuffer),
+	}
+}
+
+// Events delivers transitions in order. The channel is buffered and never
+// blocks the timer: if the owner stops draining it, further events are
+// dropped, but State and the elapsed accessors stay accurate.
+func (t *Timer) Events() <-chan Event {
+	return t.events
+}
+
+func (t *Timer) emit(eventType EventType, at time.Time) {
+	select {
+	case t.events <- Event{Type: eventType, Phase: t.phase, At: at}:
+	default:
+	}
+}
+
+// Start begins a new split now, discarding whatever the timer was doing.
+func (t *Timer) Start(plan Plan) {
+	t.StartAt(t.clock.Now(), plan)
+}
+
+// StartAt begins a split that started at the given time, which may be in
+// the past. The next Tick catches up on any phases that have already ended.
+func (t *Timer) StartAt(start time.Time, plan Plan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.plan = plan
+	t.focusElapsed = 0
+	t.restElapsed = 0
+	t.focusOvertime = 0
+	t.restOvertime = 0
+	t.state = StateRunning
+	t.startPhase(Focus, start)
+}
+
+func (t *Timer) startPhase(phase Phase, start time.Time) {
+	t.phase = phase
+	t.carried = 0
+	t.resetClock(start)
+	t.emit(PhaseStarted, start)
+}
+
+func (t *Timer) resetClock(start time.Time) {
+	switch {
+	case t.phase == Focus && t.plan.CountUp:
+		t.current = newStopwatch(start)
+	case t.phase == Focus:
+		t.current = newPhaseClock(start, t.plan.Focus)
+	default:
+		t.current = newPhaseClock(start, t.restDuration())
+	}
+	t.current.overrun = t.plan.SoftEnd
+	t.overtimeAlerted = false
+}
+
+func (t *Timer) restDuration() time.Duration {
+	return t.restFor(t.focusElapsed)
+}
+
+// restFor is the planned rest, or for count-up splits the rest earned by
+// the given focus time, rounded to whole minutes.
+func (t *Timer) restFor(focus time.Duration) time.Duration {
+	if !t.plan.CountUp {
+		return t.plan.Rest
+	}
+	divisor := t.plan.RestDivisor
+	if divisor <= 0 {
+		divisor = DefaultRestDivisor
+	}
+	rest := (focus / time.Duration(divisor)).Round(time.Minute)
+	if rest < time.Minute {
+		rest = time.Minute
+	}
+	return rest
+}
+
+func (t *Timer) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pause(t.clock.Now())
+}
+
+// PauseAt pauses the split as of a moment in the past, first catching up
+// on phases that ended before it. With ResumeAt it replays recorded
+// pauses onto a split picked up with StartAt.
+func (t *Timer) PauseAt(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(at)
+	t.pause(at)
+}
+
+func (t *Timer) pause(now time.Time) {
+	if t.state != StateRunning {
+		return
+	}
+	t.current.pause(now)
+	t.state = StatePaused
+	t.emit(Paused, now)
+}
+
+func (t *Timer) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resume(t.clock.Now())
+}
+
+// ResumeAt ends a pause replayed with PauseAt.
+func (t *Timer) ResumeAt(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resume(at)
+}
+
+func (t *Timer) resume(now time.Time) {
+	if t.state != StatePaused {
+		return
+	}
+	t.current.resume(now)
+	t.state = StateRunning
+	t.emit(Resumed, now)
+}
+
+// Cancel stops the split early, crediting the time spent in the current
+// phase so far.
+func (t *Timer) Cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != StateRunning && t.state != StatePaused {
+		return
+	}
+	now := t.clock.Now()
+	t.creditCurrent(now)
+	t.state = StateCancelled
+	t.ended = now
+	t.emit(Cancelled, now)
+}
+
+// EndPhase finishes the current phase now, whether or not its time is up.
+// This is how count-up focus phases end, and how a phase is skipped.
+func (t *Timer) EndPhase() {
+	t.EndPhaseAt(t.clock.Now())
+}
+
+// EndPhaseAt ends the phase that was running at the given moment, first
+// catching up on phases that ended before it. Like the other At methods it
+// replays a change recorded by whoever was timing the split.
+func (t *Timer) EndPhaseAt(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(now)
+	if !t.active() {
+		return
+	}
+	t.creditCurrent(now)
+	t.emit(PhaseEnded, now)
+
+	if t.phase == Focus {
+		t.state = StateRunning
+		t.startPhase(Rest, now)
+		return
+	}
+	t.state = StateFinished
+	t.ended = now
+}
+
+// Extend adds time to the current phase. Count-up phases cannot be
+// extended.
+func (t *Timer) Extend(d time.Duration) {
+	t.ExtendAt(t.clock.Now(), d)
+}
+
+func (t *Timer) ExtendAt(now time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(now)
+	if !t.active() || t.current.unbounded {
+		return
+	}
+	t.current.duration += d
+	if !t.current.expired(now) {
+		// Ring again at the new deadline
+		t.overtimeAlerted = false
+	}
+	t.emit(PhaseExtended, now)
+}
+
+// Restart begins the current phase again from its planned length. Time
+// already spent in it stays credited.
+func (t *Timer) Restart() {
+	t.RestartAt(t.clock.Now())
+}
+
+func (t *Timer) RestartAt(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(now)
+	if !t.active() {
+		return
+	}
+	t.carried += t.current.elapsed(now)
+	t.resetClock(now)
+	t.state = StateRunning
+	t.emit(PhaseRestarted, now)
+}
+
+func (t *Timer) creditCurrent(now time.Time) {
+	if t.phase == Focus {
+		t.focusElapsed = t.carried + t.current.elapsed(now)
+		t.focusOvertime = t.current.overtime(now)
+	} else {
+		t.restElapsed = t.carried + t.current.elapsed(now)
+		t.restOvertime = t.current.overtime(now)
+	}
+}
+
+// SetSoftEnd switches soft ends on or off for the rest of the split,
+// including the running phase.
+func (t *Timer) SetSoftEnd(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.plan.SoftEnd = enabled
+	t.current.overrun = enabled
+}
+
+// SoftEnd reports whether phases run into overtime instead of ending.
+func (t *Timer) SoftEnd() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.plan.SoftEnd
+}
+
+// Tick advances the state machine to the present. Every phase that ended
+// since the last call produces its own events, in order.
+func (t *Timer) Tick() {
+	t.TickAt(t.clock.Now())
+}
+
+// TickAt advances the state machine to the given moment, which must not be
+// earlier than the last one it saw.
+func (t *Timer) TickAt(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(now)
+}
+
+func (t *Timer) advance(now time.Time) {
+	if t.state == StateRunning && t.current.overrun && t.current.expired(now) {
+		if !t.overtimeAlerted {
+			t.overtimeAlerted = true
+			t.emit(PhaseOvertime, t.current.deadline())
+		}
+		return
+	}
+
+	for t.state == StateRunning && t.current.expired(now) {
+		deadline := t.current.deadline()
+		t.creditCurrent(deadline)
+		t.emit(PhaseEnded, deadline)
+
+		if t.phase == Focus {
+			t.startPhase(Rest, deadline)
+			continue
+		}
+		t.state = StateFinished
+		t.ended = deadline
+	}
+}
+
+func (t *Timer) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// EndedAt is when a finished or cancelled split ended.
+func (t *Timer) EndedAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ended
+}
+
+func (t *Timer) Phase() Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase
+}
+
+// Duration is the planned length of the current phase. It is zero for a
+// count-up focus phase.
+func (t *Timer) Duration() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.duration
+}
+
+// CountingUp reports whether the current phase is an open-ended focus.
+func (t *Timer) CountingUp() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.unbounded
+}
+
+// Elapsed is the time spent in the current phase, excluding pauses.
+func (t *Timer) Elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.elapsed(t.clock.Now())
+}
+
+// SuggestedRest is the rest a count-up focus phase has earned so far.
+func (t *Timer) SuggestedRest() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.plan.CountUp && t.phase == Focus && t.active() {
+		return t.restFor(t.current.elapsed(t.clock.Now()))
+	}
+	return t.restDuration()
+}
+
+// Overtime is how far the current phase has run past its deadline.
+func (t *Timer) Overtime() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.overtime(t.clock.Now())
+}
+
+func (t *Timer) FocusOvertime() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active() && t.phase == Focus {
+		return t.current.overtime(t.clock.Now())
+	}
+	return t.focusOvertime
+}
+
+func (t *Timer) RestOvertime() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active() && t.phase == Rest {
+		return t.current.overtime(t.clock.Now())
+	}
+	return t.restOvertime
+}
+
+// Remaining is the time left in the current phase. It goes negative once a
+// soft-end phase runs into overtime.
+func (t *Timer) Remaining() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.remaining(t.clock.Now())
+}
+
+// Progress is the completed fraction of the current phase, from 0 to 1.
+func (t *Timer) Progress() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current.unbounded {
+		return 0
+	}
+	if t.current.duration <= 0 {
+		return 1
+	}
+	if t.current.overtime(t.clock.Now()) > 0 {
+		return 1
+	}
+	return float64(t.current.elapsed(t.clock.Now())) / float64(t.current.duration)
+}
+
+func (t *Timer) active() bool {
+	return t.state == StateRunning || t.state == StatePaused
+}
+
+// FocusElapsed is the focus time to credit to the split so far.
+func (t *Timer) FocusElapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active() && t.phase == Focus {
+		return t.carried + t.current.elapsed(t.clock.Now())
+	}
+	return t.focusElapsed
+}
+
+// RestElapsed is the rest time to credit to the split so far.
+func (t *Timer) RestElapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active() && t.phase == Rest {
+		return t.carried + t.current.elapsed(t.clock.Now())
+	}
+	return t.restElapsed
+}
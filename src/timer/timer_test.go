@@ -0,0 +1,381 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when the test advances it.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestTimer() (*Timer, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	return New(clock), clock
+}
+
+// drain returns the events delivered so far.
+func drain(t *Timer) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-t.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func expectEvents(t *testing.T, got []Event, want ...EventType) {
+	t.Helper()
+	types := eventTypes(got)
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	clock.advance(10 * time.Minute)
+	timer.Pause()
+	if timer.State() != StatePaused {
+		t.Fatalf("state = %v, want paused", timer.State())
+	}
+
+	// Time spent paused is not credited and pushes the deadline back
+	clock.advance(time.Hour)
+	timer.Tick()
+	if timer.State() != StatePaused || timer.Phase() != Focus {
+		t.Fatalf("paused timer moved on: %v %v", timer.State(), timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 10*time.Minute {
+		t.Errorf("focus elapsed while paused = %v, want 10m", got)
+	}
+
+	timer.Resume()
+	clock.advance(5 * time.Minute)
+	timer.Tick()
+	if got := timer.Remaining(); got != 10*time.Minute {
+		t.Errorf("remaining = %v, want 10m", got)
+	}
+
+	clock.advance(10 * time.Minute)
+	timer.Tick()
+	if timer.Phase() != Rest {
+		t.Fatalf("phase = %v, want rest", timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 25*time.Minute {
+		t.Errorf("focus elapsed = %v, want 25m", got)
+	}
+
+	events := drain(timer)
+	expectEvents(t, events, PhaseStarted, Paused, Resumed, PhaseEnded, PhaseStarted)
+	if want := clock.now; !events[3].At.Equal(want) {
+		t.Errorf("focus ended at %v, want %v", events[3].At, want)
+	}
+}
+
+func TestPauseResumeIgnoredInWrongState(t *testing.T) {
+	timer, _ := newTestTimer()
+	timer.Resume()
+	timer.Pause()
+	if timer.State() != StateIdle {
+		t.Fatalf("state = %v, want idle", timer.State())
+	}
+
+	timer.Start(Plan{Focus: time.Minute, Rest: time.Minute})
+	timer.Resume()
+	timer.Pause()
+	timer.Pause()
+	expectEvents(t, drain(timer), PhaseStarted, Paused)
+}
+
+func TestTickCatchesUpAfterJump(t *testing.T) {
+	timer, clock := newTestTimer()
+	start := clock.now
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	// Nobody ticked through the whole split, e.g. a suspended laptop
+	clock.advance(2 * time.Hour)
+	timer.Tick()
+
+	if timer.State() != StateFinished {
+		t.Fatalf("state = %v, want finished", timer.State())
+	}
+	if got := timer.FocusElapsed(); got != 25*time.Minute {
+		t.Errorf("focus elapsed = %v, want 25m", got)
+	}
+	if got := timer.RestElapsed(); got != 5*time.Minute {
+		t.Errorf("rest elapsed = %v, want 5m", got)
+	}
+	if got := timer.EndedAt(); !got.Equal(start.Add(30 * time.Minute)) {
+		t.Errorf("ended at %v, want the rest deadline", got)
+	}
+
+	events := drain(timer)
+	expectEvents(t, events, PhaseStarted, PhaseEnded, PhaseStarted, PhaseEnded)
+	wantAt := []time.Time{
+		start,
+		start.Add(25 * time.Minute),
+		start.Add(25 * time.Minute),
+		start.Add(30 * time.Minute),
+	}
+	wantPhase := []Phase{Focus, Focus, Rest, Rest}
+	for i, event := range events {
+		if !event.At.Equal(wantAt[i]) || event.Phase != wantPhase[i] {
+			t.Errorf("event %d = %v %v at %v, want %v at %v",
+				i, event.Type, event.Phase, event.At, wantPhase[i], wantAt[i])
+		}
+	}
+}
+
+func TestStartAtInThePast(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.StartAt(clock.now.Add(-27*time.Minute), Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+	timer.Tick()
+
+	if timer.Phase() != Rest || timer.State() != StateRunning {
+		t.Fatalf("got %v %v, want running rest", timer.State(), timer.Phase())
+	}
+	if got := timer.Remaining(); got != 3*time.Minute {
+		t.Errorf("remaining = %v, want 3m", got)
+	}
+}
+
+func TestPauseAtReplaysPastPause(t *testing.T) {
+	timer, clock := newTestTimer()
+	start := clock.now.Add(-time.Hour)
+	timer.StartAt(start, Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	// Paused ten minutes in and never resumed
+	timer.PauseAt(start.Add(10 * time.Minute))
+	timer.Tick()
+	if timer.State() != StatePaused || timer.Phase() != Focus {
+		t.Fatalf("got %v %v, want paused focus", timer.State(), timer.Phase())
+	}
+	if got := timer.Remaining(); got != 15*time.Minute {
+		t.Errorf("remaining = %v, want 15m", got)
+	}
+
+	// Resuming in the past moves the deadline by the pause only
+	timer.ResumeAt(start.Add(45 * time.Minute))
+	timer.Tick()
+	if timer.Phase() != Rest || timer.State() != StateRunning {
+		t.Fatalf("got %v %v, want running rest", timer.State(), timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 25*time.Minute {
+		t.Errorf("focus elapsed = %v, want 25m", got)
+	}
+	if got := timer.Remaining(); got != 5*time.Minute {
+		t.Errorf("remaining = %v, want 5m", got)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	clock.advance(20 * time.Minute)
+	timer.Extend(10 * time.Minute)
+	if got := timer.Duration(); got != 35*time.Minute {
+		t.Errorf("duration = %v, want 35m", got)
+	}
+	if got := timer.Remaining(); got != 15*time.Minute {
+		t.Errorf("remaining = %v, want 15m", got)
+	}
+
+	// The original deadline passes without ending the phase
+	clock.advance(10 * time.Minute)
+	timer.Tick()
+	if timer.Phase() != Focus {
+		t.Fatalf("phase = %v, want focus", timer.Phase())
+	}
+
+	clock.advance(5 * time.Minute)
+	timer.Tick()
+	if timer.Phase() != Rest {
+		t.Fatalf("phase = %v, want rest", timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 35*time.Minute {
+		t.Errorf("focus elapsed = %v, want 35m", got)
+	}
+	expectEvents(t, drain(timer), PhaseStarted, PhaseExtended, PhaseEnded, PhaseStarted)
+}
+
+func TestExtendIgnoredForCountUp(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{CountUp: true})
+
+	clock.advance(time.Minute)
+	timer.Extend(5 * time.Minute)
+	if got := timer.Duration(); got != 0 {
+		t.Errorf("duration = %v, want 0", got)
+	}
+	expectEvents(t, drain(timer), PhaseStarted)
+}
+
+func TestRestartCarriesTimeOver(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	clock.advance(10 * time.Minute)
+	timer.Restart()
+	if got := timer.Remaining(); got != 25*time.Minute {
+		t.Errorf("remaining after restart = %v, want 25m", got)
+	}
+	if got := timer.Elapsed(); got != 0 {
+		t.Errorf("elapsed after restart = %v, want 0", got)
+	}
+
+	// The first attempt is still credited
+	clock.advance(5 * time.Minute)
+	if got := timer.FocusElapsed(); got != 15*time.Minute {
+		t.Errorf("focus elapsed = %v, want 15m", got)
+	}
+
+	clock.advance(20 * time.Minute)
+	timer.Tick()
+	if timer.Phase() != Rest {
+		t.Fatalf("phase = %v, want rest", timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 35*time.Minute {
+		t.Errorf("focus elapsed = %v, want 35m", got)
+	}
+
+	// Carried time belongs to its phase only
+	clock.advance(time.Minute)
+	if got := timer.RestElapsed(); got != time.Minute {
+		t.Errorf("rest elapsed = %v, want 1m", got)
+	}
+	expectEvents(t, drain(timer), PhaseStarted, PhaseRestarted, PhaseEnded, PhaseStarted)
+}
+
+func TestRestartWhilePausedResumes(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	clock.advance(10 * time.Minute)
+	timer.Pause()
+	clock.advance(10 * time.Minute)
+	timer.Restart()
+
+	if timer.State() != StateRunning {
+		t.Fatalf("state = %v, want running", timer.State())
+	}
+	if got := timer.FocusElapsed(); got != 10*time.Minute {
+		t.Errorf("focus elapsed = %v, want 10m", got)
+	}
+}
+
+func TestSoftEndOvertime(t *testing.T) {
+	timer, clock := newTestTimer()
+	start := clock.now
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute, SoftEnd: true})
+
+	clock.advance(30 * time.Minute)
+	timer.Tick()
+	timer.Tick()
+	if timer.Phase() != Focus || timer.State() != StateRunning {
+		t.Fatalf("got %v %v, want running focus", timer.State(), timer.Phase())
+	}
+	if got := timer.Remaining(); got != -5*time.Minute {
+		t.Errorf("remaining = %v, want -5m", got)
+	}
+	if got := timer.Overtime(); got != 5*time.Minute {
+		t.Errorf("overtime = %v, want 5m", got)
+	}
+	if got := timer.Progress(); got != 1 {
+		t.Errorf("progress = %v, want 1", got)
+	}
+
+	// The overtime alert fires once, dated at the deadline
+	events := drain(timer)
+	expectEvents(t, events, PhaseStarted, PhaseOvertime)
+	if want := start.Add(25 * time.Minute); !events[1].At.Equal(want) {
+		t.Errorf("overtime at %v, want %v", events[1].At, want)
+	}
+
+	timer.EndPhase()
+	if timer.Phase() != Rest {
+		t.Fatalf("phase = %v, want rest", timer.Phase())
+	}
+	if got := timer.FocusElapsed(); got != 30*time.Minute {
+		t.Errorf("focus elapsed = %v, want 30m", got)
+	}
+	if got := timer.FocusOvertime(); got != 5*time.Minute {
+		t.Errorf("focus overtime = %v, want 5m", got)
+	}
+	expectEvents(t, drain(timer), PhaseEnded, PhaseStarted)
+}
+
+func TestSoftEndExtendRingsAgain(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute, SoftEnd: true})
+
+	clock.advance(26 * time.Minute)
+	timer.Tick()
+	timer.Extend(5 * time.Minute)
+	if got := timer.Remaining(); got != 4*time.Minute {
+		t.Errorf("remaining = %v, want 4m", got)
+	}
+
+	clock.advance(5 * time.Minute)
+	timer.Tick()
+	expectEvents(t, drain(timer), PhaseStarted, PhaseOvertime, PhaseExtended, PhaseOvertime)
+}
+
+func TestCountUpRest(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{CountUp: true})
+
+	clock.advance(50 * time.Minute)
+	timer.Tick()
+	if timer.Phase() != Focus || !timer.CountingUp() {
+		t.Fatalf("count-up focus ended on its own")
+	}
+	if got := timer.SuggestedRest(); got != 10*time.Minute {
+		t.Errorf("suggested rest = %v, want 10m", got)
+	}
+
+	timer.EndPhase()
+	if got := timer.Duration(); got != 10*time.Minute {
+		t.Errorf("rest duration = %v, want 10m", got)
+	}
+}
+
+func TestCancelCreditsCurrentPhase(t *testing.T) {
+	timer, clock := newTestTimer()
+	timer.Start(Plan{Focus: 25 * time.Minute, Rest: 5 * time.Minute})
+
+	clock.advance(7 * time.Minute)
+	timer.Cancel()
+	clock.advance(time.Hour)
+
+	if timer.State() != StateCancelled {
+		t.Fatalf("state = %v, want cancelled", timer.State())
+	}
+	if got := timer.FocusElapsed(); got != 7*time.Minute {
+		t.Errorf("focus elapsed = %v, want 7m", got)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+
+	"romodoro/timer"
 )
 
 var (
@@ -83,6 +85,18 @@ var (
 			Align(lipgloss.Center)
 )
 
+// applyTheme recolors the styles above.
+func applyTheme(theme ThemeConfig) {
+	text := lipgloss.Color(theme.Text)
+	titleStyle = titleStyle.Foreground(text).Background(lipgloss.Color(theme.Accent))
+	sessionHeaderStyle = sessionHeaderStyle.Foreground(lipgloss.Color(theme.Header))
+	timerStyle = timerStyle.Foreground(text).Background(lipgloss.Color(theme.Focus))
+	restTimerStyle = restTimerStyle.Foreground(text).Background(lipgloss.Color(theme.Rest))
+	pausedStyle = pausedStyle.Foreground(text).Background(lipgloss.Color(theme.Paused))
+	helpStyle = helpStyle.Foreground(lipgloss.Color(theme.Muted))
+	selectedSessionRowStyle = selectedSessionRowStyle.Foreground(text).Background(lipgloss.Color(theme.Accent))
+}
+
 func (m *App) View() string {
 	var sections []string
 
@@ -104,6 +118,13 @@ func (m *App) View() string {
 		sections = append(sections, m.viewPaused())
 	case StateSessionBrowser:
 		sections = append(sections, m.viewSessionBrowser())
+	case StateRecovery:
+		sections = append(sections, m.viewRecovery())
+	case StateAutoContinue:
+		sections = append(sections, m.viewSessionHeader())
+		sections = append(sections, m.viewAutoContinue())
+	case StateAttached:
+		sections = append(sections, m.viewAttached())
 	}
 
 	content := lipgloss.JoinVertical(lipgloss.Center, sections...)
@@ -119,11 +140,16 @@ func (m *App) View() string {
 func (m *App) viewMainMenu() string {
 	var content strings.Builder
 
+	keys := currentConfig().Keymap
 	content.WriteString("Welcome to Romodoro!\n\n")
-	content.WriteString("1. Continue Session\n")
-	content.WriteString("2. Browse Previous Sessions\n")
-	content.WriteString("3. Create New Session\n\n")
-	content.WriteString("Press 'q' or Ctrl+C to quit")
+	content.WriteString(fmt.Sprintf("%s. Continue Session\n", keyHint(keys.ContinueSession)))
+	content.WriteString(fmt.Sprintf("%s. Browse Previous Sessions\n", keyHint(keys.BrowseSessions)))
+	content.WriteString(fmt.Sprintf("%s. Create New Session\n", keyHint(keys.NewSession)))
+	content.WriteString(fmt.Sprintf("%s. Attach to Background Timer\n\n", keyHint(keys.Attach)))
+	if m.notice != "" {
+		content.WriteString(m.notice + "\n\n")
+	}
+	content.WriteString(fmt.Sprintf("Press '%s' to quit", keyHint(keys.Quit)))
 
 	return menuStyle.Width(50).Render(content.String())
 }
@@ -139,10 +165,12 @@ func (m *App) viewSessionHeader() string {
 	content := fmt.Sprintf(
 		"📝 Session: %s\n"+
 			"🎯 Total Focus Time: %s\n"+
-			"☕ Total Rest Time: %s",
+			"☕ Total Rest Time: %s\n"+
+			"⏸️  Interruptions: %d",
 		m.session.Name,
 		focusTime,
 		restTime,
+		m.session.InterruptionCount,
 	)
 
 	return sessionHeaderStyle.Width(60).Render(content)
@@ -151,27 +179,170 @@ func (m *App) viewSessionHeader() string {
 func (m *App) viewTimerSetup() string {
 	var content strings.Builder
 
-	if m.inputStep == 0 {
+	if m.choosingPreset {
+		return m.viewPresetPicker()
+	}
+
+	switch m.inputStep {
+	case 0:
 		content.WriteString("🎯 Set Focus Time\n\n")
 		content.WriteString(m.textInput.View())
 		content.WriteString("\n\nEnter focus time in minutes and press Enter\n")
-		content.WriteString("Press 'm' to go back to main menu")
-	} else {
+	case 1:
 		content.WriteString("☕ Set Rest Time\n\n")
 		content.WriteString(fmt.Sprintf("Focus: %s minutes\n", m.focusInput))
 		content.WriteString(m.textInput.View())
 		content.WriteString("\n\nEnter rest time in minutes and press Enter\n")
-		content.WriteString("Press 'm' to go back to main menu")
+	case 2:
+		content.WriteString("🔁 Set Cycle Length\n\n")
+		content.WriteString(fmt.Sprintf("Focus: %s minutes • Rest: %s minutes\n", m.focusInput, m.restInput))
+		content.WriteString(m.textInput.View())
+		content.WriteString("\n\nEnter how many pomodoros to run before a long break\n")
+	case 4:
+		content.WriteString("💾 Save Preset\n\n")
+		content.WriteString(fmt.Sprintf("%s\n", formatPlan(m.cycle.focusMinutes, m.cycle.restMinutes, m.cycle.length, m.cycle.longBreakMinutes)))
+		content.WriteString(m.textInput.View())
+		content.WriteString("\n\nPress Enter to start • Esc for main menu")
+		return inputStyle.Width(60).Render(content.String())
+	default:
+		content.WriteString("🛋️  Set Long Break\n\n")
+		content.WriteString(fmt.Sprintf("%s × (%s/%s)\n", m.cycleInput, m.focusInput, m.restInput))
+		content.WriteString(m.textInput.View())
+		content.WriteString("\n\nEnter long break time in minutes and press Enter\n")
 	}
+	content.WriteString(fmt.Sprintf("Press '%s' to go back to main menu", keyHint(currentConfig().Keymap.Menu)))
 
 	return inputStyle.Width(60).Render(content.String())
 }
 
+func (m *App) viewPresetPicker() string {
+	var content strings.Builder
+
+	content.WriteString("⏱️  Choose a Preset\n\n")
+
+	for i, preset := range m.presets {
+		row := fmt.Sprintf("%-14s %-14s %-6s", preset.Name,
+			formatPlan(preset.FocusMinutes, preset.RestMinutes, preset.CycleLength, preset.LongBreakMinutes),
+			formatAutoContinue(preset.AutoContinue, preset.AutoContinueGraceSeconds))
+		if i == m.selectedPreset {
+			content.WriteString(selectedSessionRowStyle.Render("→ "+row) + "\n")
+		} else {
+			content.WriteString(sessionRowStyle.Render("  "+row) + "\n")
+		}
+	}
+
+	custom := fmt.Sprintf("%-35s", "Custom…")
+	if m.selectedPreset == len(m.presets) {
+		content.WriteString(selectedSessionRowStyle.Render("→ "+custom) + "\n")
+	} else {
+		content.WriteString(sessionRowStyle.Render("  "+custom) + "\n")
+	}
+
+	keys := currentConfig().Keymap
+	content.WriteString(fmt.Sprintf("\n↑/↓ or j/k to choose • Enter to start • '%s' custom • '%s' flowtime\n",
+		keyHint(keys.Custom), keyHint(keys.Flowtime)))
+	content.WriteString(fmt.Sprintf("'%s' toggle auto-continue • '%s' delete preset • '%s' main menu",
+		keyHint(keys.AutoContinue), keyHint(keys.Delete), keyHint(keys.Menu)))
+
+	return inputStyle.Width(60).Render(content.String())
+}
+
+// formatAutoContinue renders an auto-continue setting for compact lists.
+func formatAutoContinue(enabled bool, graceSeconds int) string {
+	switch {
+	case !enabled:
+		return ""
+	case graceSeconds > 0:
+		return fmt.Sprintf("▶ %ds", graceSeconds)
+	}
+	return "▶ now"
+}
+
+func (m *App) viewAutoContinue() string {
+	var content strings.Builder
+
+	content.WriteString("🔁 NEXT SPLIT STARTING\n\n")
+	plan := formatPlan(m.cycle.focusMinutes, m.cycle.restMinutes, m.cycle.length, m.cycle.longBreakMinutes)
+	if m.cycle.flowtime {
+		plan = "Flowtime"
+	}
+	content.WriteString(fmt.Sprintf("%s in %ds\n\n", plan, max(m.autoContinueRemaining(), 0)))
+	keys := currentConfig().Keymap
+	content.WriteString(fmt.Sprintf("Press '%s' to start now • '%s' to cancel • '%s' main menu",
+		keyHint(keys.StartNext), keyHint(keys.CancelNext), keyHint(keys.Menu)))
+
+	return restTimerStyle.Width(70).Render(content.String())
+}
+
+func (m *App) viewAttached() string {
+	var content strings.Builder
+	status := m.attached
+
+	style := timerStyle
+	switch {
+	case status.State == "idle":
+		content.WriteString("🛰  BACKGROUND TIMER IDLE\n\n")
+		content.WriteString("Start a split with `romodoro start`\n\n")
+	case status.State == "paused":
+		content.WriteString(fmt.Sprintf("⏸️  PAUSED (%s)\n\n", status.Phase))
+		style = pausedStyle
+	case status.Phase == "rest":
+		content.WriteString("☕ REST TIME\n\n")
+		style = restTimerStyle
+	default:
+		content.WriteString("🎯 FOCUS TIME\n\n")
+	}
+
+	if status.State != "idle" {
+		if status.CountingUp {
+			content.WriteString(fmt.Sprintf("Elapsed: %s\n\n", m.formatDuration(status.ElapsedSeconds)))
+		} else {
+			content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", m.formatDuration(status.RemainingSeconds)))
+		}
+		content.WriteString(fmt.Sprintf("📝 Session: %s\n\n", status.SessionName))
+	}
+	if m.notice != "" {
+		content.WriteString(m.notice + "\n\n")
+	}
+
+	content.WriteString("Running in the background daemon\n")
+	keys := currentConfig().Keymap
+	content.WriteString(fmt.Sprintf("'%s' pause • '%s' resume • '%s' skip phase • '%s' stop\n",
+		keyHint(keys.Pause), keyHint(keys.Resume), keyHint(keys.Skip), keyHint(keys.Stop)))
+	content.WriteString(fmt.Sprintf("'%s' detach (the timer keeps running)", keyHint(keys.Detach)))
+
+	return style.Width(70).Render(content.String())
+}
+
+func formatExtension(seconds int) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (+%dm)", seconds/60)
+}
+
+// formatPlan renders a plan as e.g. "25/5" or "4×25/5 +15".
+func formatPlan(focusMinutes, restMinutes, cycleLength, longBreakMinutes int) string {
+	if cycleLength > 1 {
+		return fmt.Sprintf("%d×%d/%d +%d", cycleLength, focusMinutes, restMinutes, longBreakMinutes)
+	}
+	return fmt.Sprintf("%d/%d", focusMinutes, restMinutes)
+}
+
+// keyHint names the first key bound to an action.
+func keyHint(keys keyList) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
 func (m *App) viewTimer() string {
 	var content strings.Builder
 	var style lipgloss.Style
+	keys := currentConfig().Keymap
 
-	if m.phase == PhaseFocus {
+	if m.timer.Phase() == timer.Focus {
 		content.WriteString("🎯 FOCUS TIME\n\n")
 		style = timerStyle
 	} else {
@@ -179,24 +350,77 @@ func (m *App) viewTimer() string {
 		style = restTimerStyle
 	}
 
+	if m.timer.CountingUp() {
+		// Flowtime focus has no end to show progress towards
+		elapsed := m.formatDuration(int(m.timer.Elapsed().Seconds()))
+		content.WriteString(fmt.Sprintf("Elapsed: %s\n\n", elapsed))
+		content.WriteString(fmt.Sprintf("Suggested rest so far: %dm\n\n", int(m.timer.SuggestedRest().Minutes())))
+		content.WriteString(fmt.Sprintf("Press '%s' to finish focus and start resting\n", keyHint(keys.Finish)))
+		content.WriteString(fmt.Sprintf("'%s' pause • '%s' back to session • '%s' main menu",
+			keyHint(keys.Pause), keyHint(keys.Back), keyHint(keys.Menu)))
+		return style.Width(70).Render(content.String())
+	}
+
 	// Progress bar
-	progress := float64(m.totalSeconds-m.remainingSeconds) / float64(m.totalSeconds)
-	progressBar := m.progress.ViewAs(progress)
+	progressBar := m.progress.ViewAs(m.timer.Progress())
 	content.WriteString(progressBar)
 	content.WriteString("\n\n")
 
-	// Time remaining
-	timeStr := m.formatDuration(m.remainingSeconds)
-	content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", timeStr))
+	// Time remaining, or how far past the end a soft-end phase has run
+	if overtime := m.timer.Overtime(); overtime > 0 {
+		content.WriteString(fmt.Sprintf("⏰ OVERTIME +%s — press Enter to move on\n\n",
+			m.formatDuration(int(overtime.Seconds()))))
+	} else {
+		timeStr := m.formatDuration(m.remainingSeconds())
+		content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", timeStr))
+	}
 
 	// Current split info
-	content.WriteString(fmt.Sprintf(
-		"Current Split: %dm focus / %dm rest\n\n",
-		m.currentSplit.FocusMinutes,
-		m.currentSplit.RestMinutes,
-	))
+	if m.currentSplit.Mode == SplitModeFlowtime {
+		content.WriteString(fmt.Sprintf(
+			"Flowtime: %s focus / %dm rest\n\n",
+			m.formatDuration(int(m.timer.FocusElapsed().Seconds())),
+			m.currentSplit.RestMinutes,
+		))
+	} else if m.currentSplit.CycleLength > 1 {
+		content.WriteString(fmt.Sprintf(
+			"Pomodoro %d of %d\n",
+			m.currentSplit.CyclePosition,
+			m.currentSplit.CycleLength,
+		))
+	}
+	if m.currentSplit.Mode != SplitModeFlowtime {
+		restLabel := "rest"
+		if m.currentSplit.CycleLength > 1 && m.currentSplit.CyclePosition == m.currentSplit.CycleLength {
+			restLabel = "long break"
+		}
+		content.WriteString(fmt.Sprintf(
+			"Current Split: %dm focus%s / %dm %s%s\n\n",
+			m.currentSplit.FocusMinutes,
+			formatExtension(m.currentSplit.FocusExtensionSeconds),
+			m.currentSplit.RestMinutes,
+			restLabel,
+			formatExtension(m.currentSplit.RestExtensionSeconds),
+		))
+	}
 
-	content.WriteString("Press 'p' to pause • 'b' back to session • 'm' main menu")
+	if m.session != nil && m.session.AutoContinue {
+		content.WriteString(fmt.Sprintf("Auto-continue: %s\n\n",
+			formatAutoContinue(true, m.session.AutoContinueGraceSeconds)))
+	}
+	if m.session != nil && m.session.SoftEnd {
+		content.WriteString("Soft end: phases wait for you when time is up\n\n")
+	}
+	if m.notifyErr != nil {
+		content.WriteString(fmt.Sprintf("⚠ Notification failed: %v\n\n", m.notifyErr))
+	}
+
+	content.WriteString(fmt.Sprintf("Press '%s' to pause • '%s' back to session • '%s' main menu\n",
+		keyHint(keys.Pause), keyHint(keys.Back), keyHint(keys.Menu)))
+	content.WriteString(fmt.Sprintf("'%s' add %d min • '%s' skip phase • '%s' restart phase\n",
+		keyHint(keys.Extend), currentConfig().Timer.ExtendMinutes, keyHint(keys.Skip), keyHint(keys.Restart)))
+	content.WriteString(fmt.Sprintf("'%s' toggle auto-continue • '%s' toggle soft end for this session",
+		keyHint(keys.AutoContinue), keyHint(keys.SoftEnd)))
 
 	return style.Width(70).Render(content.String())
 }
@@ -206,17 +430,43 @@ func (m *App) viewPaused() string {
 
 	content.WriteString("⏸️  PAUSED\n\n")
 
-	// Time remaining
-	timeStr := m.formatDuration(m.remainingSeconds)
-	content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", timeStr))
+	if m.timer.CountingUp() {
+		timeStr := m.formatDuration(int(m.timer.Elapsed().Seconds()))
+		content.WriteString(fmt.Sprintf("Elapsed: %s\n\n", timeStr))
+	} else {
+		// Time remaining
+		timeStr := m.formatDuration(m.remainingSeconds())
+		content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", timeStr))
+	}
 
-	if m.phase == PhaseFocus {
+	if m.timer.Phase() == timer.Focus {
 		content.WriteString("Phase: 🎯 Focus\n\n")
 	} else {
 		content.WriteString("Phase: ☕ Rest\n\n")
 	}
 
-	content.WriteString("Press 's' or 'c' to continue • 'b' back to session • 'm' main menu")
+	keys := currentConfig().Keymap
+	if m.interruption != nil {
+		if m.enteringReason {
+			content.WriteString(m.textInput.View())
+			content.WriteString("\n\nPress Enter to save • Esc to cancel")
+			return pausedStyle.Width(70).Render(content.String())
+		}
+
+		kind := m.interruption.Kind
+		if kind == "" {
+			kind = "unspecified"
+		}
+		content.WriteString(fmt.Sprintf("Interruption: %s", kind))
+		if m.interruption.Reason != "" {
+			content.WriteString(fmt.Sprintf(" — %s", m.interruption.Reason))
+		}
+		content.WriteString(fmt.Sprintf("\n'%s' internal • '%s' external • '%s' type a reason\n\n",
+			keyHint(keys.Internal), keyHint(keys.External), keyHint(keys.Reason)))
+	}
+
+	content.WriteString(fmt.Sprintf("Press '%s' to continue • '%s' back to session • '%s' main menu",
+		keyHint(keys.Resume), keyHint(keys.Back), keyHint(keys.Menu)))
 
 	return pausedStyle.Width(70).Render(content.String())
 }
@@ -233,8 +483,8 @@ func (m *App) viewSessionBrowser() string {
 	}
 
 	// Header - removed "Session Name" and "Status"
-	header := fmt.Sprintf("%-15s %-15s %-12s %-12s",
-		"Started", "Ended", "Focus", "Rest")
+	header := fmt.Sprintf("%-15s %-15s %-10s %-10s %-6s",
+		"Started", "Ended", "Focus", "Rest", "Int.")
 	content.WriteString(header + "\n")
 	content.WriteString(strings.Repeat("─", 60) + "\n")
 
@@ -245,8 +495,8 @@ func (m *App) viewSessionBrowser() string {
 		restStr := m.formatDuration(session.TotalRestSeconds)
 		startedStr := session.StartTime.Format("01-02 15:04")
 
-		row := fmt.Sprintf("%-15s %-15s %-12s %-12s",
-			startedStr, endedStr, focusStr, restStr)
+		row := fmt.Sprintf("%-15s %-15s %-10s %-10s %-6d",
+			startedStr, endedStr, focusStr, restStr, session.InterruptionCount)
 
 		if i == m.selectedSession {
 			content.WriteString(selectedSessionRowStyle.Render("→ "+row) + "\n")
@@ -256,12 +506,82 @@ func (m *App) viewSessionBrowser() string {
 	}
 
 	content.WriteString("\n")
-	content.WriteString("↑/↓ or j/k to navigate • 'x' to delete • 'm' to go back")
+	if m.notice != "" {
+		content.WriteString(m.notice + "\n\n")
+	}
+	keys := currentConfig().Keymap
+	content.WriteString(fmt.Sprintf("↑/↓ or j/k to navigate • '%s' to export as CSV • '%s' to delete • '%s' to go back",
+		keyHint(keys.Export), keyHint(keys.Delete), keyHint(keys.Back)))
 
 	return browserStyle.Width(70).Render(content.String())
 }
 
+func (m *App) viewRecovery() string {
+	split := m.currentOrphan()
+	if split == nil {
+		return ""
+	}
+
+	var content strings.Builder
+	content.WriteString("⚠️  Unfinished Split Found\n\n")
+	content.WriteString(fmt.Sprintf("Started: %s\n", split.StartTime.Format("01-02 15:04")))
+	if split.Mode != SplitModeFlowtime {
+		content.WriteString(fmt.Sprintf("Planned: %dm focus / %dm rest\n\n", split.FocusMinutes, split.RestMinutes))
+	}
+
+	// Where the split would be now had it kept running, pauses and all
+	t, err := splitTimer(m.store, split, time.Now())
+	switch {
+	case err != nil:
+		content.WriteString(fmt.Sprintf("Could not rebuild the timer: %v\n\n", err))
+	case t.State() == timer.StateFinished:
+		content.WriteString("The split would have finished by now.\n\n")
+	default:
+		phase := "🎯 Focus"
+		if t.Phase() == timer.Rest {
+			phase = "☕ Rest"
+		}
+		if t.State() == timer.StatePaused {
+			phase += " (paused)"
+		}
+		content.WriteString(fmt.Sprintf("Phase: %s\n", phase))
+		remaining := int(t.Remaining().Round(time.Second).Seconds())
+		switch {
+		case t.CountingUp():
+			content.WriteString("Flowtime focus, still counting up.\n\n")
+		case remaining < 0:
+			content.WriteString(fmt.Sprintf("Overtime: %s\n\n", m.formatDuration(-remaining)))
+		default:
+			content.WriteString(fmt.Sprintf("Time Remaining: %s\n\n", m.formatDuration(remaining)))
+		}
+	}
+	if err == nil {
+		content.WriteString(fmt.Sprintf("Focus so far: %s • Rest so far: %s\n\n",
+			m.formatDuration(int(t.FocusElapsed().Seconds())), m.formatDuration(int(t.RestElapsed().Seconds()))))
+	}
+
+	if len(m.orphans) > 1 {
+		content.WriteString(fmt.Sprintf("%d more unfinished splits after this one\n\n", len(m.orphans)-1))
+	}
+	if m.notice != "" {
+		content.WriteString(m.notice + "\n\n")
+	}
+
+	keys := currentConfig().Keymap
+	content.WriteString(fmt.Sprintf("'%s' resume • '%s' mark completed • '%s' cancel (credit partial time)",
+		keyHint(keys.ResumeSplit), keyHint(keys.CompleteSplit), keyHint(keys.CancelSplit)))
+
+	return inputStyle.Width(70).Render(content.String())
+}
+
+func (m *App) remainingSeconds() int {
+	return int(m.timer.Remaining().Round(time.Second).Seconds())
+}
+
 func (m *App) formatDuration(seconds int) string {
+	if seconds < 0 {
+		return "-" + m.formatDuration(-seconds)
+	}
 	duration := time.Duration(seconds) * time.Second
 	minutes := int(duration.Minutes())
 	secs := int(duration.Seconds()) % 60
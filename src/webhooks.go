@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	webhookTimeout   = 10 * time.Second
+	webhookInterval  = 5 * time.Second
+	webhookBatch     = 20
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = time.Hour
+	webhookMaxAge    = 7 * 24 * time.Hour
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// webhooksQueued tells a one-shot CLI command that it has something to
+// send before exiting.
+var webhooksQueued atomic.Bool
+
+// queueWebhooks puts body in the outbox once for every target that wants
+// event. Nothing is sent here; the outbox is drained by deliverWebhooks.
+func queueWebhooks(store Store, event string, body []byte, at time.Time) {
+	for _, target := range currentConfig().Webhooks {
+		if len(target.Events) > 0 && !slices.Contains(target.Events, event) {
+			continue
+		}
+		_, err := store.QueueWebhook(WebhookDelivery{
+			URL:           target.URL,
+			Event:         event,
+			Payload:       string(body),
+			CreatedAt:     at,
+			NextAttemptAt: at,
+		})
+		if err != nil {
+			logHookFailure(event, target.URL, err)
+			continue
+		}
+		webhooksQueued.Store(true)
+	}
+}
+
+// deliverWebhooks sends the deliveries that are due. A failed one is
+// retried with exponential backoff until it is webhookMaxAge old; one for
+// a target no longer in the config is dropped.
+func deliverWebhooks(store Store, client *http.Client, now time.Time) error {
+	// Claim for longer than a request can take, so a slow batch is not
+	// picked up a second time by the next run
+	deliveries, err := store.ClaimWebhooks(now, now.Add(webhookBatch*webhookTimeout), webhookBatch)
+	if err != nil {
+		return err
+	}
+
+	targets := currentConfig().Webhooks
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		index := slices.IndexFunc(targets, func(target WebhookConfig) bool { return target.URL == delivery.URL })
+		if index < 0 {
+			logHookFailure(delivery.Event, delivery.URL, fmt.Errorf("dropped: the webhook is no longer configured"))
+			if err := store.DeleteWebhook(delivery.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sendErr := postWebhook(client, targets[index].Secret, delivery, time.Now())
+		if sendErr == nil {
+			if err := store.DeleteWebhook(delivery.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.LastError = sendErr.Error()
+		if now.Sub(delivery.CreatedAt) >= webhookMaxAge {
+			logHookFailure(delivery.Event, delivery.URL, fmt.Errorf("gave up after %d attempts: %w", delivery.Attempts, sendErr))
+			if err := store.DeleteWebhook(delivery.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if delivery.Attempts == 1 {
+			logHookFailure(delivery.Event, delivery.URL, fmt.Errorf("will retry: %w", sendErr))
+		}
+		delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+		if err := store.UpdateWebhook(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookBackoff doubles from webhookRetryBase up to webhookRetryMax.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookRetryBase
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return backoff
+}
+
+func postWebhook(client *http.Client, secret string, delivery *WebhookDelivery, now time.Time) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "romodoro")
+	req.Header.Set("X-Romodoro-Event", delivery.Event)
+	req.Header.Set("X-Romodoro-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Romodoro-Timestamp", timestamp)
+	req.Header.Set("X-Romodoro-Signature", "sha256="+signWebhook(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook is the hex HMAC-SHA256 of "timestamp.body". Signing the
+// timestamp lets receivers reject old requests replayed at them.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// flushWebhooks makes one delivery attempt before a CLI command exits.
+// Whatever fails stays in the outbox for the TUI or daemon to retry.
+func flushWebhooks(store Store) {
+	if webhooksQueued.Load() {
+		deliverWebhooks(store, webhookClient, time.Now())
+	}
+}
+
+// runWebhooks drains the outbox until ctx is done.
+func runWebhooks(ctx context.Context, store Store) {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := deliverWebhooks(store, webhookClient, now); err != nil {
+				logHookFailure("webhooks", "", err)
+			}
+		}
+	}
+}
+
+type webhookCheckMsg struct{}
+
+// webhookCheckCmd drains the outbox for the TUI. Tea runs commands off the
+// UI goroutine, so slow targets don't freeze the screen.
+func webhookCheckCmd(store Store) tea.Cmd {
+	return tea.Tick(webhookInterval, func(now time.Time) tea.Msg {
+		if err := deliverWebhooks(store, webhookClient, now); err != nil {
+			logHookFailure("webhooks", "", err)
+		}
+		return webhookCheckMsg{}
+	})
+}
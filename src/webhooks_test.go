@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records what it receives and answers with the next status
+// in statuses, then 200 once they run out.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	t.Helper()
+	server := &webhookServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.requests = append(server.requests, r)
+		server.bodies = append(server.bodies, body)
+		status := http.StatusOK
+		if len(server.statuses) > 0 {
+			status, server.statuses = server.statuses[0], server.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// withWebhooks installs a config with the given targets for one test and
+// keeps failure logging out of the real data directory.
+func withWebhooks(t *testing.T, targets ...WebhookConfig) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	previous := currentConfig()
+	config := defaultConfig()
+	config.Webhooks = targets
+	setConfig(config)
+	t.Cleanup(func() { setConfig(previous) })
+}
+
+// pendingWebhooks is how many deliveries are left in the outbox.
+func pendingWebhooks(t *testing.T, store Store) int {
+	t.Helper()
+	deliveries, err := store.ClaimWebhooks(time.Now().Add(365*24*time.Hour), time.Now(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(deliveries)
+}
+
+func TestDeliverWebhooksSigned(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		server := newWebhookServer(t)
+		other := newWebhookServer(t)
+		withWebhooks(t,
+			WebhookConfig{URL: server.URL, Secret: "s3cret"},
+			WebhookConfig{URL: other.URL, Events: []string{"rest_start"}},
+		)
+
+		now := wallNow()
+		body := []byte(`{"event":"focus_start"}`)
+		queueWebhooks(store, "focus_start", body, now)
+		if err := deliverWebhooks(store, http.DefaultClient, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if server.hits() != 1 {
+			t.Fatalf("target got %d requests, want 1", server.hits())
+		}
+		if other.hits() != 0 {
+			t.Errorf("target not subscribed to the event got %d requests", other.hits())
+		}
+
+		req := server.requests[0]
+		if string(server.bodies[0]) != string(body) {
+			t.Errorf("body = %s, want %s", server.bodies[0], body)
+		}
+		if got := req.Header.Get("X-Romodoro-Event"); got != "focus_start" {
+			t.Errorf("X-Romodoro-Event = %q", got)
+		}
+
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(req.Header.Get("X-Romodoro-Timestamp") + "."))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if got := req.Header.Get("X-Romodoro-Signature"); !hmac.Equal([]byte(got), []byte(want)) {
+			t.Errorf("X-Romodoro-Signature = %q, want %q", got, want)
+		}
+
+		if n := pendingWebhooks(t, store); n != 0 {
+			t.Errorf("%d deliveries left after a 2xx, want 0", n)
+		}
+	})
+}
+
+func TestDeliverWebhooksRetriesWithBackoff(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		server := newWebhookServer(t, http.StatusInternalServerError, http.StatusInternalServerError)
+		withWebhooks(t, WebhookConfig{URL: server.URL})
+
+		now := wallNow()
+		queueWebhooks(store, "focus_start", []byte(`{}`), now)
+
+		deliver := func(at time.Time, wantHits int) {
+			t.Helper()
+			if err := deliverWebhooks(store, http.DefaultClient, at); err != nil {
+				t.Fatal(err)
+			}
+			if server.hits() != wantHits {
+				t.Fatalf("after %v: %d requests, want %d", at.Sub(now), server.hits(), wantHits)
+			}
+		}
+
+		deliver(now, 1)
+
+		// The first retry waits webhookRetryBase, the next twice that
+		deliver(now.Add(webhookRetryBase-time.Second), 1)
+		retry := now.Add(webhookRetryBase)
+		deliver(retry, 2)
+		deliver(retry.Add(2*webhookRetryBase-time.Second), 2)
+		deliver(retry.Add(2*webhookRetryBase), 3)
+
+		if n := pendingWebhooks(t, store); n != 0 {
+			t.Errorf("%d deliveries left after the retry succeeded, want 0", n)
+		}
+	})
+}
+
+func TestDeliverWebhooksGivesUp(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		server := newWebhookServer(t, http.StatusInternalServerError)
+		withWebhooks(t, WebhookConfig{URL: server.URL})
+
+		created := wallNow().Add(-webhookMaxAge)
+		queueWebhooks(store, "focus_start", []byte(`{}`), created)
+		if err := deliverWebhooks(store, http.DefaultClient, wallNow()); err != nil {
+			t.Fatal(err)
+		}
+
+		if server.hits() != 1 {
+			t.Fatalf("%d requests, want 1", server.hits())
+		}
+		if n := pendingWebhooks(t, store); n != 0 {
+			t.Errorf("%d deliveries left past webhookMaxAge, want 0", n)
+		}
+	})
+}
+
+func TestDeliverWebhooksDropsUnconfiguredTarget(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		server := newWebhookServer(t)
+		withWebhooks(t, WebhookConfig{URL: server.URL})
+
+		now := wallNow()
+		queueWebhooks(store, "focus_start", []byte(`{}`), now)
+
+		// The target is removed from the config before delivery
+		withWebhooks(t)
+		if err := deliverWebhooks(store, http.DefaultClient, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if server.hits() != 0 {
+			t.Errorf("removed target got %d requests, want 0", server.hits())
+		}
+		if n := pendingWebhooks(t, store); n != 0 {
+			t.Errorf("%d deliveries left for a removed target, want 0", n)
+		}
+	})
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, webhookRetryBase},
+		{2, 2 * webhookRetryBase},
+		{3, 4 * webhookRetryBase},
+		{20, webhookRetryMax},
+	}
+	for _, test := range tests {
+		if got := webhookBackoff(test.attempts); got != test.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}